@@ -0,0 +1,135 @@
+package addons
+
+import (
+	vpk "DeadlockHelper/VPK"
+	"path/filepath"
+	"sort"
+)
+
+// Provider — pak, в котором есть файл игры
+type Provider struct {
+	Path string // путь к pakNN_dir.vpk
+	Slot int
+	CRC  uint32
+}
+
+// Conflict — файл игры, который подменяют несколько pak. Providers отсортированы по
+// номеру pak: первый побеждает, остальные игра не видит.
+type Conflict struct {
+	Asset     string
+	Providers []Provider
+}
+
+// Winner возвращает pak, чей файл увидит игра
+func (c Conflict) Winner() Provider {
+	return c.Providers[0]
+}
+
+// ConflictReport — результат проверки addons на конфликты
+type ConflictReport struct {
+	Conflicts []Conflict // по алфавиту путей
+	Skipped   []error    // pak, которые не удалось прочитать
+}
+
+// Conflicts читает деревья всех pakNN_dir.vpk в dir и возвращает файлы, которые
+// подменяют больше одного pak. Одинаковые по CRC копии файла конфликтом не считаются.
+func Conflicts(dir string) (ConflictReport, error) {
+	slots, err := sortedSlots(dir)
+	if err != nil {
+		return ConflictReport{}, err
+	}
+
+	var report ConflictReport
+	providers := make(map[string][]Provider)
+	for _, slot := range slots {
+		pakPath := filepath.Join(dir, SlotName(slot))
+		a, err := vpk.Open(pakPath)
+		if err != nil {
+			report.Skipped = append(report.Skipped, err)
+			continue
+		}
+		for _, e := range a.Entries {
+			providers[e.Path] = append(providers[e.Path], Provider{Path: pakPath, Slot: slot, CRC: e.CRC})
+		}
+	}
+
+	for asset, list := range providers {
+		if len(list) < 2 || sameContent(list) {
+			continue
+		}
+		report.Conflicts = append(report.Conflicts, Conflict{Asset: asset, Providers: list})
+	}
+	sort.Slice(report.Conflicts, func(i, j int) bool {
+		return report.Conflicts[i].Asset < report.Conflicts[j].Asset
+	})
+	return report, nil
+}
+
+// sameContent сообщает, что все pak содержат одну и ту же версию файла
+func sameContent(list []Provider) bool {
+	for _, p := range list[1:] {
+		if p.CRC != list[0].CRC {
+			return false
+		}
+	}
+	return true
+}
+
+// Overlap — файлы нового мода, которые уже подменяет установленный pak
+type Overlap struct {
+	Provider Provider
+	Assets   []string // по алфавиту
+}
+
+// Overlaps сравнивает ещё не установленный VPK candidate с pak в dir и возвращает
+// для каждого pak файлы, которые есть и там, и в candidate (с другим содержимым).
+// Результат отсортирован по номеру pak.
+func Overlaps(candidate, dir string) ([]Overlap, error) {
+	newPak, err := vpk.Open(candidate)
+	if err != nil {
+		return nil, err
+	}
+	newCRC := make(map[string]uint32, len(newPak.Entries))
+	for _, e := range newPak.Entries {
+		newCRC[e.Path] = e.CRC
+	}
+
+	slots, err := sortedSlots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlaps []Overlap
+	for _, slot := range slots {
+		pakPath := filepath.Join(dir, SlotName(slot))
+		a, err := vpk.Open(pakPath)
+		if err != nil {
+			continue // нечитаемый pak показывает отчёт о конфликтах, здесь он не мешает установке
+		}
+		var assets []string
+		for _, e := range a.Entries {
+			if crc, ok := newCRC[e.Path]; ok && crc != e.CRC {
+				assets = append(assets, e.Path)
+			}
+		}
+		if len(assets) > 0 {
+			sort.Strings(assets)
+			overlaps = append(overlaps, Overlap{Provider: Provider{Path: pakPath, Slot: slot}, Assets: assets})
+		}
+	}
+	return overlaps, nil
+}
+
+// sortedSlots возвращает занятые слоты dir по возрастанию номера
+func sortedSlots(dir string) ([]int, error) {
+	used, err := UsedSlots(dir)
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]int, 0, len(used))
+	for slot := range used {
+		slots = append(slots, slot)
+	}
+	sort.Ints(slots)
+	return slots, nil
+}
@@ -0,0 +1,35 @@
+package addons
+
+import (
+	vpk "DeadlockHelper/VPK"
+	"regexp"
+	"sort"
+)
+
+// heroAssetRe выделяет имя героя из пути модели или её материалов:
+//
+//	models/heroes_staging/haze/haze.vmdl_c
+//	materials/models/heroes_staging/haze/haze_color.vtex_c
+var heroAssetRe = regexp.MustCompile(`(?:^|/)models/heroes(?:_staging)?/([^/]+)/`)
+
+// Heroes возвращает имена героев (по названию папки модели), чьи модели и материалы
+// подменяет VPK, по алфавиту. Для модов интерфейса, звука и карт список пуст.
+func Heroes(vpkPath string) ([]string, error) {
+	a, err := vpk.Open(vpkPath)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var heroes []string
+	for _, e := range a.Entries {
+		m := heroAssetRe.FindStringSubmatch(e.Path)
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		heroes = append(heroes, m[1])
+	}
+	sort.Strings(heroes)
+	return heroes, nil
+}
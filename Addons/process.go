@@ -0,0 +1,21 @@
+package addons
+
+import "errors"
+
+// gameExecutable — имя исполняемого файла игры
+const gameExecutable = "deadlock.exe"
+
+// ErrGameRunning возвращается операциями, которые нельзя выполнять при открытой игре
+var ErrGameRunning = errors.New("deadlock is running, close the game first")
+
+// EnsureGameClosed возвращает ErrGameRunning, если Deadlock запущен
+func EnsureGameClosed() error {
+	running, err := GameRunning()
+	if err != nil {
+		return err
+	}
+	if running {
+		return ErrGameRunning
+	}
+	return nil
+}
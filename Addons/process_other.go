@@ -0,0 +1,28 @@
+//go:build !windows
+
+package addons
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GameRunning сообщает, запущен ли сейчас Deadlock. Вне Windows игра работает через
+// Proton, поэтому ищем deadlock.exe в командных строках процессов из /proc.
+func GameRunning() (bool, error) {
+	cmdlines, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return false, err
+	}
+	for _, path := range cmdlines {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // процесс успел завершиться
+		}
+		if strings.Contains(strings.ToLower(string(data)), gameExecutable) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
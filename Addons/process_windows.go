@@ -0,0 +1,31 @@
+package addons
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// GameRunning сообщает, запущен ли сейчас Deadlock
+func GameRunning() (bool, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return false, err
+	}
+	defer windows.CloseHandle(snapshot)
+
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	err = windows.Process32First(snapshot, &entry)
+	for err == nil {
+		if strings.EqualFold(windows.UTF16ToString(entry.ExeFile[:]), gameExecutable) {
+			return true, nil
+		}
+		err = windows.Process32Next(snapshot, &entry)
+	}
+	if err == windows.ERROR_NO_MORE_FILES {
+		return false, nil
+	}
+	return false, err
+}
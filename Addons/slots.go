@@ -0,0 +1,258 @@
+package addons
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// MaxSlot — последний номер pak, который можно выдать моду (pak99_dir.vpk)
+const MaxSlot = 99
+
+// ErrNoFreeSlot возвращается, когда все слоты pak01..pak99 заняты
+var ErrNoFreeSlot = errors.New("no free pak slot in addons")
+
+// slotRe распознаёт файлы, занимающие слот: pakNN_dir.vpk и его части pakNN_000.vpk
+var slotRe = regexp.MustCompile(`(?i)^pak(\d{2})_(dir|\d{3})\.vpk$`)
+
+// Dir возвращает путь к папке addons внутри папки Deadlock
+func Dir(rootPath string) string {
+	return filepath.Join(rootPath, "game", "citadel", "addons")
+}
+
+// SlotName возвращает имя VPK для слота: 5 -> pak05_dir.vpk
+func SlotName(slot int) string {
+	return fmt.Sprintf("pak%02d_dir.vpk", slot)
+}
+
+// ParseSlot возвращает номер слота по имени файла в addons
+func ParseSlot(name string) (int, bool) {
+	m := slotRe.FindStringSubmatch(filepath.Base(name))
+	if m == nil {
+		return 0, false
+	}
+	slot, err := strconv.Atoi(m[1])
+	if err != nil || slot < 1 || slot > MaxSlot {
+		return 0, false
+	}
+	return slot, true
+}
+
+// UsedSlots возвращает слоты, занятые файлами в папке addons
+func UsedSlots(dir string) (map[int]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[int]bool{}, nil
+		}
+		return nil, err
+	}
+	used := make(map[int]bool)
+	for _, e := range entries {
+		if slot, ok := ParseSlot(e.Name()); ok {
+			used[slot] = true
+		}
+	}
+	return used, nil
+}
+
+// NextFreeSlot возвращает слот для нового мода: следующий после самого большого занятого,
+// чтобы новый мод оказался в конце порядка загрузки. Если занят pak99, берётся первый пропуск.
+func NextFreeSlot(dir string) (int, error) {
+	used, err := UsedSlots(dir)
+	if err != nil {
+		return 0, err
+	}
+	highest := 0
+	for slot := range used {
+		if slot > highest {
+			highest = slot
+		}
+	}
+	if highest < MaxSlot {
+		return highest + 1, nil
+	}
+	for slot := 1; slot <= MaxSlot; slot++ {
+		if !used[slot] {
+			return slot, nil
+		}
+	}
+	return 0, ErrNoFreeSlot
+}
+
+// Install переносит готовый VPK srcPath (лежащий в той же папке addons) в следующий
+// свободный слот. Существующий pak никогда не перезаписывается, даже если папку
+// одновременно меняет кто-то ещё.
+func Install(srcPath, dir string) (string, error) {
+	for attempt := 0; attempt < MaxSlot; attempt++ {
+		slot, err := NextFreeSlot(dir)
+		if err != nil {
+			return "", err
+		}
+		destPath, err := InstallAt(srcPath, dir, slot)
+		if errors.Is(err, fs.ErrExist) {
+			continue // слот успели занять, пробуем следующий
+		}
+		return destPath, err
+	}
+	return "", ErrNoFreeSlot
+}
+
+// Renumber переименовывает VPK из paths в pak01, pak02, ... в указанном порядке и убирает
+// пропуски между номерами. Меньший номер загружается раньше, поэтому первый мод в списке
+// побеждает, если несколько модов заменяют один и тот же файл игры.
+//
+// Сначала все файлы получают временные имена, чтобы при перестановке двух модов один
+// не затёр другой. Слоты, занятые VPK, которых нет в paths, пропускаются. Возвращает
+// новые пути в том же порядке, что и paths. При ошибке имена файлов восстанавливаются.
+func Renumber(dir string, paths []string) ([]string, error) {
+	listed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		listed[filepath.Clean(p)] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	foreign := make(map[int]bool)
+	for _, e := range entries {
+		if slot, ok := ParseSlot(e.Name()); ok && !listed[filepath.Join(dir, e.Name())] {
+			foreign[slot] = true
+		}
+	}
+
+	targets := make([]string, len(paths))
+	slot := 0
+	for i := range paths {
+		slot++
+		for foreign[slot] {
+			slot++
+		}
+		if slot > MaxSlot {
+			return nil, ErrNoFreeSlot
+		}
+		targets[i] = filepath.Join(dir, SlotName(slot))
+	}
+
+	temps := make([]string, len(paths))
+	for i, p := range paths {
+		temps[i] = filepath.Join(dir, fmt.Sprintf(".reorder-%02d.tmp", i))
+		if err := os.Rename(p, temps[i]); err != nil {
+			renameAll(temps[:i], paths[:i])
+			return nil, fmt.Errorf("failed to rename %s: %w", filepath.Base(p), err)
+		}
+	}
+	for i := range temps {
+		if err := os.Rename(temps[i], targets[i]); err != nil {
+			renameAll(targets[:i], temps[:i])
+			renameAll(temps, paths)
+			return nil, fmt.Errorf("failed to rename %s: %w", filepath.Base(paths[i]), err)
+		}
+	}
+	return targets, nil
+}
+
+// renameAll переименовывает from[i] в to[i], продолжая после ошибок; используется для отката
+func renameAll(from, to []string) {
+	for i := range from {
+		os.Rename(from[i], to[i])
+	}
+}
+
+// DisabledDir возвращает папку выключенных модов. Она лежит рядом с addons,
+// но не входит в пути поиска из gameinfo.gi, поэтому игра её не загружает.
+func DisabledDir(rootPath string) string {
+	return filepath.Join(rootPath, "game", "citadel", "addons_disabled")
+}
+
+// Disable переносит VPK мода из addons в папку выключенных модов и возвращает новый путь
+func Disable(vpkPath, rootPath string, id int) (string, error) {
+	disabledDir := DisabledDir(rootPath)
+	if err := os.MkdirAll(disabledDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create disabled dir: %w", err)
+	}
+	destPath := filepath.Join(disabledDir, fmt.Sprintf("mod_%d.vpk", id))
+	for i := 1; fileExists(destPath); i++ {
+		destPath = filepath.Join(disabledDir, fmt.Sprintf("mod_%d_%d.vpk", id, i))
+	}
+	if err := os.Rename(vpkPath, destPath); err != nil {
+		return "", fmt.Errorf("failed to move vpk to disabled dir: %w", err)
+	}
+	return destPath, nil
+}
+
+// Enable возвращает выключенный VPK в addons. Если слот slot свободен, мод встаёт
+// прямо в него. Иначе файл кладётся в addons под временным именем (как Restore),
+// и вызывающий должен перенумеровать моды через Renumber.
+func Enable(vpkPath, rootPath string, slot int) (string, error) {
+	dir := Dir(rootPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create addons dir: %w", err)
+	}
+	if slot >= 1 && slot <= MaxSlot {
+		destPath, err := InstallAt(vpkPath, dir, slot)
+		if err == nil {
+			return destPath, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		return Restore(vpkPath, rootPath)
+	}
+
+	// места в порядке нет — ставим в конец
+	pending, err := Restore(vpkPath, rootPath)
+	if err != nil {
+		return "", err
+	}
+	return Install(pending, dir)
+}
+
+// Restore переносит выключенный VPK в addons под временным именем, которое игра
+// не загружает. Номер pak файлу потом выдаёт Renumber.
+func Restore(vpkPath, rootPath string) (string, error) {
+	dir := Dir(rootPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create addons dir: %w", err)
+	}
+	pending := filepath.Join(dir, fmt.Sprintf(".enable-%s.tmp", filepath.Base(vpkPath)))
+	if err := os.Rename(vpkPath, pending); err != nil {
+		return "", fmt.Errorf("failed to move vpk to addons: %w", err)
+	}
+	return pending, nil
+}
+
+// InstallAt переносит srcPath в слот slot папки addons. Если слот занят, возвращает
+// ошибку, для которой errors.Is(err, fs.ErrExist) истинно, и ничего не трогает.
+func InstallAt(srcPath, dir string, slot int) (string, error) {
+	destPath := filepath.Join(dir, SlotName(slot))
+	if used, err := UsedSlots(dir); err != nil {
+		return "", err
+	} else if used[slot] {
+		return "", fmt.Errorf("pak slot %d: %w", slot, fs.ErrExist)
+	}
+
+	// Слот резервируется созданием файла с O_EXCL: если его успел занять кто-то ещё,
+	// OpenFile вернёт fs.ErrExist, и чужой pak останется нетронутым
+	placeholder, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to reserve pak slot: %w", err)
+	}
+	placeholder.Close()
+
+	if err := os.Rename(srcPath, destPath); err != nil {
+		os.Remove(destPath)
+		return "", fmt.Errorf("failed to move vpk into pak slot: %w", err)
+	}
+	return destPath, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -10,7 +10,8 @@ type Config struct {
 	DeadlockPath string `json:"deadlock_path"`
 }
 
-func getConfigPath() (string, error) {
+// Dir возвращает папку настроек программы, создавая её при необходимости
+func Dir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -19,6 +20,14 @@ func getConfigPath() (string, error) {
 	if err := os.MkdirAll(configDir, 0755); err != nil {
 		return "", err
 	}
+	return configDir, nil
+}
+
+func getConfigPath() (string, error) {
+	configDir, err := Dir()
+	if err != nil {
+		return "", err
+	}
 	return filepath.Join(configDir, "config.json"), nil
 }
 
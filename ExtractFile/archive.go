@@ -0,0 +1,213 @@
+package extractfile
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/nwaples/rardecode"
+)
+
+// archiveEntry — запись архива без данных
+type archiveEntry struct {
+	Name string
+	Mode fs.FileMode
+}
+
+// archive — открытый архив любого поддерживаемого формата. Сначала читается список
+// записей, затем распаковываются только нужные, без копии всего архива на диске.
+type archive interface {
+	// list возвращает записи архива, не распаковывая их данные
+	list() ([]archiveEntry, error)
+	// extract вызывает fn с данными каждой записи из wanted в порядке следования в архиве
+	extract(wanted map[string]bool, fn func(e archiveEntry, r io.Reader) error) error
+	Close() error
+}
+
+// openArchive открывает архив известного формата. Пароль используется только для RAR и 7z.
+func openArchive(format archiveFormat, archivePath, password string) (archive, error) {
+	switch format {
+	case formatZIP:
+		return openZIP(archivePath)
+	case formatRAR4, formatRAR5:
+		return openRAR(archivePath, password)
+	case format7z:
+		return open7z(archivePath, password)
+	case formatGzip, formatXZ, formatZstd, formatLZ4, formatTar:
+		return &streamArchive{path: archivePath, format: format}, nil
+	}
+	return nil, fmt.Errorf("unsupported archive format: %s", format)
+}
+
+// zipArchive читает ZIP с произвольным доступом к записям
+type zipArchive struct {
+	r *zip.ReadCloser
+}
+
+func openZIP(zipPath string) (*zipArchive, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip: %w", err)
+	}
+	return &zipArchive{r: r}, nil
+}
+
+func (a *zipArchive) list() ([]archiveEntry, error) {
+	entries := make([]archiveEntry, 0, len(a.r.File))
+	for _, f := range a.r.File {
+		entries = append(entries, archiveEntry{Name: f.Name, Mode: f.Mode()})
+	}
+	return entries, nil
+}
+
+func (a *zipArchive) extract(wanted map[string]bool, fn func(e archiveEntry, r io.Reader) error) error {
+	for _, f := range a.r.File {
+		if !wanted[f.Name] {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open file %s in archive: %w", f.Name, err)
+		}
+		err = fn(archiveEntry{Name: f.Name, Mode: f.Mode()}, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *zipArchive) Close() error {
+	return a.r.Close()
+}
+
+// rarArchive читает RAR потоком, поэтому каждый проход заново открывает архив.
+// Следующие тома многотомного архива ищутся рядом с первым.
+type rarArchive struct {
+	path      string
+	password  string
+	encrypted bool
+}
+
+func openRAR(rarPath, password string) (*rarArchive, error) {
+	encrypted, err := rarEncrypted(rarPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rar header: %w", err)
+	}
+	if encrypted && password == "" {
+		return nil, &PasswordError{Archive: rarPath}
+	}
+	return &rarArchive{path: rarPath, password: password, encrypted: encrypted}, nil
+}
+
+// walk проходит по всем записям архива, вызывая fn для каждой
+func (a *rarArchive) walk(fn func(hdr *rardecode.FileHeader, r io.Reader) error) error {
+	rr, err := rardecode.OpenReader(a.path, a.password)
+	if err != nil {
+		return a.wrap(fmt.Errorf("failed to create rar reader: %w", err))
+	}
+	defer rr.Close()
+
+	for {
+		hdr, err := rr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return a.wrap(fmt.Errorf("error reading rar: %w", err))
+		}
+		if err := fn(hdr, rr); err != nil {
+			return a.wrap(err)
+		}
+	}
+}
+
+// wrap превращает ошибку чтения зашифрованного архива в *PasswordError
+func (a *rarArchive) wrap(err error) error {
+	if a.encrypted {
+		return passwordError(a.path, a.password, err)
+	}
+	return err
+}
+
+func (a *rarArchive) list() ([]archiveEntry, error) {
+	var entries []archiveEntry
+	err := a.walk(func(hdr *rardecode.FileHeader, _ io.Reader) error {
+		entries = append(entries, archiveEntry{Name: hdr.Name, Mode: hdr.Mode()})
+		return nil
+	})
+	return entries, err
+}
+
+func (a *rarArchive) extract(wanted map[string]bool, fn func(e archiveEntry, r io.Reader) error) error {
+	return a.walk(func(hdr *rardecode.FileHeader, r io.Reader) error {
+		if !wanted[hdr.Name] {
+			return nil
+		}
+		if err := fn(archiveEntry{Name: hdr.Name, Mode: hdr.Mode()}, r); err != nil {
+			return fmt.Errorf("failed to extract file from rar: %w", err)
+		}
+		return nil
+	})
+}
+
+func (a *rarArchive) Close() error {
+	return nil
+}
+
+// sevenZipArchive читает 7z; многотомный архив открывается по тому .7z.001
+type sevenZipArchive struct {
+	path     string
+	password string
+	r        *sevenzip.ReadCloser
+}
+
+func open7z(archivePath, password string) (*sevenZipArchive, error) {
+	r, err := sevenzip.OpenReaderWithPassword(archivePath, password)
+	if err != nil {
+		if is7zEncrypted(err) {
+			return nil, passwordError(archivePath, password, err)
+		}
+		return nil, fmt.Errorf("failed to open 7z: %w", err)
+	}
+	return &sevenZipArchive{path: archivePath, password: password, r: r}, nil
+}
+
+func (a *sevenZipArchive) list() ([]archiveEntry, error) {
+	entries := make([]archiveEntry, 0, len(a.r.File))
+	for _, f := range a.r.File {
+		entries = append(entries, archiveEntry{Name: f.Name, Mode: f.Mode()})
+	}
+	return entries, nil
+}
+
+func (a *sevenZipArchive) extract(wanted map[string]bool, fn func(e archiveEntry, r io.Reader) error) error {
+	for _, f := range a.r.File {
+		if !wanted[f.Name] {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			if is7zEncrypted(err) {
+				return passwordError(a.path, a.password, err)
+			}
+			return fmt.Errorf("failed to open file %s in archive: %w", f.Name, err)
+		}
+		err = fn(archiveEntry{Name: f.Name, Mode: f.Mode()}, rc)
+		rc.Close()
+		if is7zEncrypted(err) {
+			return passwordError(a.path, a.password, err)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *sevenZipArchive) Close() error {
+	return a.r.Close()
+}
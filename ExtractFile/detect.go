@@ -0,0 +1,106 @@
+package extractfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// archiveFormat — формат скачанного файла, определённый по сигнатуре
+type archiveFormat int
+
+const (
+	formatUnknown archiveFormat = iota
+	formatZIP
+	formatRAR4
+	formatRAR5
+	format7z
+	formatGzip
+	formatXZ
+	formatZstd
+	formatLZ4
+	formatTar
+	formatVPK
+)
+
+func (f archiveFormat) String() string {
+	switch f {
+	case formatZIP:
+		return "zip"
+	case formatRAR4:
+		return "rar4"
+	case formatRAR5:
+		return "rar5"
+	case format7z:
+		return "7z"
+	case formatGzip:
+		return "gzip"
+	case formatXZ:
+		return "xz"
+	case formatZstd:
+		return "zstd"
+	case formatLZ4:
+		return "lz4"
+	case formatTar:
+		return "tar"
+	case formatVPK:
+		return "vpk"
+	}
+	return "unknown"
+}
+
+// Сигнатуры форматов. Порядок важен: RAR5 проверяется раньше RAR4,
+// потому что их сигнатуры совпадают в первых семи байтах.
+var signatures = []struct {
+	format archiveFormat
+	magic  []byte
+}{
+	{formatZIP, []byte("PK\x03\x04")},
+	{formatZIP, []byte("PK\x05\x06")}, // пустой архив
+	{formatZIP, []byte("PK\x07\x08")}, // архив, разбитый на части
+	{formatRAR5, []byte("Rar!\x1a\x07\x01\x00")},
+	{formatRAR4, []byte("Rar!\x1a\x07\x00")},
+	{format7z, []byte("7z\xbc\xaf\x27\x1c")},
+	{formatGzip, []byte("\x1f\x8b")},
+	{formatXZ, []byte("\xfd7zXZ\x00")},
+	{formatZstd, []byte("\x28\xb5\x2f\xfd")},
+	{formatLZ4, []byte("\x04\x22\x4d\x18")},
+	{formatVPK, []byte("\x34\x12\xaa\x55")}, // 0x55AA1234, little-endian
+}
+
+const (
+	// tarHeaderSize — размер заголовка tar; из начала файла читается столько байт,
+	// чтобы увидеть магию "ustar" по смещению 257
+	tarHeaderSize  = 512
+	tarMagicOffset = 257
+)
+
+// detectFormat определяет формат файла по первым байтам, не глядя на расширение
+func detectFormat(path string) (archiveFormat, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return formatUnknown, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, tarHeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return formatUnknown, fmt.Errorf("failed to read file header: %w", err)
+	}
+	return detectHeader(header[:n]), nil
+}
+
+// detectHeader сопоставляет начало файла с известными сигнатурами
+func detectHeader(header []byte) archiveFormat {
+	for _, sig := range signatures {
+		if bytes.HasPrefix(header, sig.magic) {
+			return sig.format
+		}
+	}
+	if len(header) >= tarMagicOffset+5 && string(header[tarMagicOffset:tarMagicOffset+5]) == "ustar" {
+		return formatTar
+	}
+	return formatUnknown
+}
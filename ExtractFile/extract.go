@@ -1,246 +1,388 @@
 package extractfile
 
 import (
-	"archive/zip"
+	addons "DeadlockHelper/Addons"
+	store "DeadlockHelper/Store"
+	vpk "DeadlockHelper/VPK"
+	"DeadlockHelper/internal/fsutil"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
-
-	"github.com/bodgit/sevenzip"
-	"github.com/nwaples/rardecode"
 )
 
-// ExtractAndInstallVPK распаковывает ZIP, RAR или 7z, находит .vpk и устанавливает его в папку addons
-func ExtractAndInstallVPK(archivePath string, rootPath string) (string, error) {
-	fmt.Println("Starting extraction for:", archivePath)
+// Installed — установленный в addons VPK
+type Installed struct {
+	Path   string // путь к pakNN_dir.vpk в addons
+	SHA256 string // хеш VPK, под которым он лежит в хранилище
+}
 
-	// 1. Создаём временную папку
-	tmpDir, err := os.MkdirTemp("", "mod_extract_")
+// Install определяет формат скачанного файла по сигнатуре, распаковывает ZIP, RAR, 7z
+// или tar (в том числе .tar.gz/.tar.xz/.tar.zst), находит .vpk и устанавливает его в
+// папку addons. Голый VPK устанавливается напрямую. RAR и 7z расшифровываются паролем;
+// для зашифрованного архива без пароля или с неверным паролем возвращается
+// *PasswordError. Многотомные архивы передаются путём к первому тому.
+//
+// Из архива распаковывается только сам VPK: скриншоты и readme на диск не попадают.
+// Копия VPK кладётся в хранилище, чтобы переустановка не требовала повторной загрузки.
+func Install(archivePath, rootPath, password string) (Installed, error) {
+	staged, err := Stage(archivePath, password)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return Installed{}, err
 	}
-	defer func() {
-		fmt.Println("Removing temp dir:", tmpDir)
-		os.RemoveAll(tmpDir)
-	}()
+	defer staged.Discard()
+	return staged.Install(rootPath)
+}
 
-	// 2. Распаковываем архив в зависимости от расширения
-	switch ext := strings.ToLower(filepath.Ext(archivePath)); ext {
-	case ".zip":
-		fmt.Println("Detected ZIP archive")
-		err = extractZIP(archivePath, tmpDir)
-	case ".rar":
-		fmt.Println("Detected RAR archive")
-		err = extractRAR(archivePath, tmpDir)
-	case ".7z":
-		fmt.Println("Detected 7z archive")
-		err = extract7z(archivePath, tmpDir)
-	default:
-		err = fmt.Errorf("unsupported archive format: %s", ext)
-	}
-	if err != nil {
-		return "", fmt.Errorf("failed to extract archive: %w", err)
-	}
-
-	// 3. Ищем .vpk файл
-	var vpkPath string
-	err = filepath.Walk(tmpDir, func(path string, info os.FileInfo, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-		if !info.IsDir() && strings.HasSuffix(strings.ToLower(info.Name()), ".vpk") {
-			vpkPath = path
-			fmt.Println("Found .vpk file:", vpkPath)
-			return io.EOF // прерываем Walk
-		}
-		return nil
-	})
-	if err != nil && err != io.EOF {
-		return "", fmt.Errorf("error walking extracted files: %w", err)
+// Staged — VPK, извлечённый из архива, но ещё не установленный в addons. Пока он не
+// установлен, его можно осмотреть (например, проверить конфликты с другими модами).
+type Staged struct {
+	Path   string // путь к извлечённому VPK вне addons
+	SHA256 string // пусто, если VPK не попал в хранилище
+
+	temp bool   // Path — временный файл, который нужно удалить
+	sum  string // SHA-256 временного файла, до переноса в хранилище
+}
+
+// Stage распаковывает VPK из архива (или берёт голый VPK) в хранилище, не трогая addons.
+// Скачанный архив после этого удаляется вместе с остальными томами. Если хранилище
+// недоступно, VPK кладётся во временную папку.
+func Stage(archivePath, password string) (*Staged, error) {
+	stagingDir, err := store.Dir()
+	if err != nil {
+		fmt.Println("Store is unavailable, staging in temp dir:", err)
+		stagingDir = os.TempDir()
 	}
-	if vpkPath == "" {
-		return "", errors.New("no .vpk file found in archive")
+	staged, err := stage(archivePath, password, stagingDir)
+	if err != nil {
+		return nil, err
 	}
 
-	// 4. Копируем .vpk
-	addonsDir := filepath.Join(rootPath, "game", "citadel", "addons")
-	if err := os.MkdirAll(addonsDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create addons dir: %w", err)
+	// Битый или недокачанный VPK роняет игру при загрузке, такой мод не устанавливаем
+	// и в хранилище не кладём
+	if err := vpk.Verify(staged.Path); err != nil {
+		staged.Discard()
+		return nil, fmt.Errorf("%w: %w", ErrInvalidVPK, err)
 	}
+	staged.adopt()
 
-	destPath := filepath.Join(addonsDir, filepath.Base(vpkPath))
-	if err := copyFile(vpkPath, destPath); err != nil {
-		return "", fmt.Errorf("failed to copy vpk file: %w", err)
+	// Удаляем исходный архив вместе с остальными томами
+	for _, volume := range archiveVolumes(archivePath) {
+		if err := os.Remove(volume); err != nil {
+			staged.Discard()
+			return nil, fmt.Errorf("failed to delete archive: %w", err)
+		}
+		fmt.Println("Deleted archive file:", volume)
 	}
-	fmt.Println("Copied .vpk file to:", destPath)
 
-	// 5. Удаляем исходный архив
-	if err := os.Remove(archivePath); err != nil {
-		return "", fmt.Errorf("failed to delete archive: %w", err)
-	}
-	fmt.Println("Deleted archive file:", archivePath)
+	return staged, nil
+}
 
-	return destPath, nil
+// Peek достаёт VPK из скачанного архива, чтобы посмотреть его содержимое. В отличие
+// от Stage, архив остаётся на месте, а VPK не проверяется и не попадает в хранилище:
+// смотреть обычно нужно как раз сломанный мод. Голый VPK возвращается как есть.
+// После просмотра вызовите Discard.
+func Peek(archivePath, password string) (*Staged, error) {
+	if vpk.IsVPK(archivePath) {
+		return &Staged{Path: archivePath}, nil
+	}
+	return stage(archivePath, password, os.TempDir())
 }
 
-// extractZIP распаковывает ZIP архив в указанную папку
-func extractZIP(zipPath, dstDir string) error {
-	r, err := zip.OpenReader(zipPath)
+// stage извлекает VPK из архива во временный файл в stagingDir
+func stage(archivePath, password, stagingDir string) (*Staged, error) {
+	fmt.Println("Starting extraction for:", archivePath)
+
+	format, err := detectFormat(archivePath)
 	if err != nil {
-		return fmt.Errorf("failed to open zip: %w", err)
+		return nil, err
 	}
-	defer r.Close()
+	fmt.Println("Detected format:", format)
 
-	for _, f := range r.File {
-		path := filepath.Join(dstDir, f.Name)
+	var staged *Staged
+	if format == formatVPK {
+		staged, err = stageVPKFile(archivePath, stagingDir)
+	} else {
+		staged, err = stageFromArchive(format, archivePath, stagingDir, password, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Staged .vpk file at:", staged.Path)
+	return staged, nil
+}
 
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(path, 0755); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", path, err)
-			}
-			continue
-		}
+// adopt переносит извлечённый VPK в хранилище. Одинаковые VPK хранятся один раз.
+// Без хранилища мод всё равно устанавливается, просто переустановка будет дороже.
+func (s *Staged) adopt() {
+	if !s.temp || s.sum == "" {
+		return
+	}
+	storePath, err := store.Adopt(s.Path, s.sum)
+	if err != nil {
+		fmt.Println("Failed to add vpk to store:", err)
+		return
+	}
+	s.Path, s.SHA256, s.temp = storePath, s.sum, false
+}
 
-		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			return fmt.Errorf("failed to create directory for file %s: %w", path, err)
-		}
+// Install ставит извлечённый VPK в следующий свободный слот addons. VPK из хранилища
+// ставится жёсткой ссылкой, без копирования.
+func (s *Staged) Install(rootPath string) (Installed, error) {
+	addonsDir := addons.Dir(rootPath)
+	if err := os.MkdirAll(addonsDir, 0755); err != nil {
+		return Installed{}, fmt.Errorf("failed to create addons dir: %w", err)
+	}
 
-		rc, err := f.Open()
+	if s.SHA256 != "" {
+		destPath, err := store.Install(s.SHA256, addonsDir)
 		if err != nil {
-			return fmt.Errorf("failed to open file %s in archive: %w", f.Name, err)
+			return Installed{}, err
 		}
+		fmt.Println("Installed .vpk file to:", destPath)
+		return Installed{Path: destPath, SHA256: s.SHA256}, nil
+	}
 
-		dstFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			rc.Close()
-			return fmt.Errorf("failed to create file %s: %w", path, err)
-		}
+	src, err := os.Open(s.Path)
+	if err != nil {
+		return Installed{}, fmt.Errorf("failed to open vpk file: %w", err)
+	}
+	defer src.Close()
 
-		if _, err := io.Copy(dstFile, rc); err != nil {
-			rc.Close()
-			dstFile.Close()
-			return fmt.Errorf("failed to copy file %s: %w", path, err)
-		}
+	destPath, err := installStream(src, addonsDir)
+	if err != nil {
+		return Installed{}, err
+	}
+	fmt.Println("Installed .vpk file to:", destPath)
+	return Installed{Path: destPath}, nil
+}
 
-		rc.Close()
-		dstFile.Close()
+// Discard удаляет временный VPK. VPK в хранилище остаётся до очистки хранилища.
+func (s *Staged) Discard() {
+	if s.temp {
+		os.Remove(s.Path)
 	}
-	return nil
 }
 
-// extractRAR распаковывает RAR архив в указанную папку
-func extractRAR(rarPath, dstDir string) error {
-	file, err := os.Open(rarPath)
+// maxNestingDepth — сколько уровней вложенных архивов просматривается в поисках VPK
+const maxNestingDepth = 3
+
+var errNoVPK = errors.New("no .vpk file found in archive")
+
+// ErrInvalidVPK — VPK в архиве повреждён и не будет установлен
+var ErrInvalidVPK = errors.New("vpk failed validation")
+
+// ErrChunkedVPK — VPK в архиве разбит на _dir.vpk и куски _NNN.vpk, такие моды не устанавливаются
+var ErrChunkedVPK = errors.New("multi-chunk vpk is not supported")
+
+// nestedArchiveExts — расширения записей, которые стоит открыть как вложенный архив
+var nestedArchiveExts = map[string]bool{
+	".zip": true, ".rar": true, ".7z": true, ".001": true,
+	".tar": true, ".gz": true, ".tgz": true, ".xz": true, ".txz": true,
+	".zst": true, ".lz4": true,
+}
+
+// stageFromArchive находит VPK среди записей архива и распаковывает только его
+// в stagingDir. Если VPK нет, открываются вложенные архивы (например, .zip внутри .7z)
+// до maxNestingDepth уровней.
+func stageFromArchive(format archiveFormat, archivePath, stagingDir, password string, depth int) (*Staged, error) {
+	a, err := openArchive(format, archivePath, password)
 	if err != nil {
-		return fmt.Errorf("failed to open rar: %w", err)
+		return nil, fmt.Errorf("failed to extract archive: %w", err)
 	}
-	defer file.Close()
+	defer a.Close()
 
-	rr, err := rardecode.NewReader(file, "")
+	guard, err := newExtractGuard(archivePath, stagingDir, DefaultLimits)
 	if err != nil {
-		return fmt.Errorf("failed to create rar reader: %w", err)
+		return nil, err
 	}
 
-	for {
-		hdr, err := rr.Next()
-		if err == io.EOF {
-			break
-		}
+	entries, err := a.list()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract archive: %w", err)
+	}
+	vpkName, nested, err := selectEntries(guard, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	if vpkName != "" {
+		fmt.Println("Found .vpk file:", vpkName)
+		var staged *Staged
+		err := a.extract(map[string]bool{vpkName: true}, func(e archiveEntry, r io.Reader) error {
+			var err error
+			staged, err = stageStream(&guardedReader{r: r, g: guard}, stagingDir)
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("error reading rar: %w", err)
+			return nil, fmt.Errorf("failed to extract vpk: %w", err)
 		}
+		return staged, nil
+	}
 
-		outPath := filepath.Join(dstDir, hdr.Name)
-		if hdr.IsDir {
-			if err := os.MkdirAll(outPath, 0755); err != nil {
-				return fmt.Errorf("failed to create dir in rar: %w", err)
-			}
+	if depth >= maxNestingDepth || len(nested) == 0 {
+		return nil, errNoVPK
+	}
+	return stageFromNested(a, guard, nested, stagingDir, password, depth)
+}
+
+// chunkVPKRe распознаёт куски многотомного VPK: pak01_000.vpk
+var chunkVPKRe = regexp.MustCompile(`(?i)_\d{3}\.vpk$`)
+
+// selectEntries проверяет все записи архива и выбирает VPK для установки
+// (первый по имени, не считая кусков pakNN_NNN.vpk) и кандидатов во вложенные
+// архивы. В слот addons ставится один файл, поэтому VPK из _dir.vpk и кусков
+// отклоняется с ErrChunkedVPK: без кусков такой мод не загрузится.
+func selectEntries(guard *extractGuard, entries []archiveEntry) (string, []string, error) {
+	var vpks, chunks, nested []string
+	for _, e := range entries {
+		if _, err := guard.entry(e.Name, e.Mode); err != nil {
+			return "", nil, err
+		}
+		if e.Mode.IsDir() {
 			continue
 		}
-
-		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
-			return fmt.Errorf("failed to create dir for file in rar: %w", err)
+		name := strings.ToLower(e.Name)
+		switch {
+		case chunkVPKRe.MatchString(name):
+			chunks = append(chunks, name)
+		case strings.HasSuffix(name, ".vpk"):
+			vpks = append(vpks, e.Name)
+		case nestedArchiveExts[path.Ext(name)]:
+			nested = append(nested, e.Name)
 		}
-
-		outFile, err := os.Create(outPath)
-		if err != nil {
-			return fmt.Errorf("failed to create file in rar: %w", err)
+	}
+	if len(vpks) == 0 {
+		if len(chunks) > 0 {
+			return "", nil, fmt.Errorf("%w: %s without its _dir.vpk", ErrChunkedVPK, chunks[0])
 		}
-
-		if _, err := io.Copy(outFile, rr); err != nil {
-			outFile.Close()
-			return fmt.Errorf("failed to extract file from rar: %w", err)
+		return "", nested, nil
+	}
+	sort.Strings(vpks)
+	chosen := vpks[0]
+	if dirName := strings.ToLower(chosen); strings.HasSuffix(dirName, "_dir.vpk") {
+		prefix := strings.TrimSuffix(dirName, "dir.vpk")
+		for _, chunk := range chunks {
+			if chunk[:len(chunk)-len("000.vpk")] == prefix {
+				return "", nil, fmt.Errorf("%w: %s", ErrChunkedVPK, chosen)
+			}
 		}
-
-		outFile.Close()
 	}
-	return nil
+	return chosen, nested, nil
 }
 
-// extract7z распаковывает 7z архив в указанную папку
-func extract7z(archivePath, dstDir string) error {
-	r, err := sevenzip.OpenReader(archivePath)
+// stageFromNested распаковывает вложенные архивы во временную папку и ищет VPK в них
+func stageFromNested(a archive, guard *extractGuard, nested []string, stagingDir, password string, depth int) (*Staged, error) {
+	tmpDir, err := os.MkdirTemp("", "mod_extract_")
 	if err != nil {
-		return fmt.Errorf("failed to open 7z: %w", err)
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
 	}
-	defer r.Close()
-
-	for _, f := range r.File {
-		outPath := filepath.Join(dstDir, f.Name)
-
-		if f.FileInfo().IsDir() {
-			if err := os.MkdirAll(outPath, f.FileInfo().Mode()); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", outPath, err)
-			}
-			continue
-		}
-
-		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
-			return fmt.Errorf("failed to create directory for file %s: %w", outPath, err)
-		}
+	defer func() {
+		fmt.Println("Removing temp dir:", tmpDir)
+		os.RemoveAll(tmpDir)
+	}()
 
-		inFile, err := f.Open()
+	wanted := make(map[string]bool, len(nested))
+	for _, name := range nested {
+		wanted[name] = true
+	}
+	var extracted []string
+	err = a.extract(wanted, func(e archiveEntry, r io.Reader) error {
+		outPath, err := fsutil.SafeJoin(tmpDir, e.Name)
 		if err != nil {
-			return fmt.Errorf("failed to open file %s in archive: %w", f.Name, err)
+			return err
 		}
+		extracted = append(extracted, outPath)
+		return guard.writeEntry(outPath, r)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract nested archive: %w", err)
+	}
 
-		dstFile, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, f.FileInfo().Mode())
+	for _, nestedPath := range extracted {
+		format, err := detectFormat(nestedPath)
 		if err != nil {
-			inFile.Close()
-			return fmt.Errorf("failed to create file %s: %w", outPath, err)
+			return nil, err
 		}
-
-		if _, err := io.Copy(dstFile, inFile); err != nil {
-			inFile.Close()
-			dstFile.Close()
-			return fmt.Errorf("failed to copy file %s: %w", outPath, err)
+		if format == formatUnknown || format == formatVPK {
+			continue
+		}
+		fmt.Println("Opening nested archive:", nestedPath)
+		staged, err := stageFromArchive(format, nestedPath, stagingDir, password, depth+1)
+		if !errors.Is(err, errNoVPK) {
+			return staged, err
 		}
-
-		inFile.Close()
-		dstFile.Close()
 	}
-	return nil
+	return nil, errNoVPK
 }
 
-// copyFile копирует файл из srcPath в dstPath
-func copyFile(srcPath, dstPath string) error {
-	src, err := os.Open(srcPath)
+// stageVPKFile извлекает скачанный голый VPK
+func stageVPKFile(vpkPath, stagingDir string) (*Staged, error) {
+	src, err := os.Open(vpkPath)
 	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+		return nil, fmt.Errorf("failed to open vpk file: %w", err)
 	}
 	defer src.Close()
 
-	dst, err := os.Create(dstPath)
+	return stageStream(src, stagingDir)
+}
+
+// stageStream пишет VPK во временный файл в stagingDir, по пути считая SHA-256.
+// В хранилище файл переносит Staged.adopt.
+func stageStream(r io.Reader, stagingDir string) (*Staged, error) {
+	tmp, err := os.CreateTemp(stagingDir, ".stage-*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
-	defer dst.Close()
+	tmpPath := tmp.Name()
 
-	if _, err := io.Copy(dst, src); err != nil {
-		return fmt.Errorf("failed to copy data: %w", err)
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), r); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to write vpk file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to flush vpk file: %w", err)
 	}
-	return nil
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to write vpk file: %w", err)
+	}
+
+	return &Staged{Path: tmpPath, temp: true, sum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// installStream пишет VPK во временный файл внутри addonsDir и атомарно переносит его
+// в следующий свободный слот pakNN_dir.vpk. Игра никогда не видит недописанный pak,
+// а уже установленные моды не перезаписываются.
+func installStream(r io.Reader, addonsDir string) (string, error) {
+	tmp, err := os.CreateTemp(addonsDir, ".install-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // после успешного переименования файла уже нет
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write vpk file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to flush vpk file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write vpk file: %w", err)
+	}
+
+	return addons.Install(tmpPath, addonsDir)
 }
@@ -0,0 +1,303 @@
+package extractfile
+
+import (
+	vpk "DeadlockHelper/VPK"
+	"DeadlockHelper/internal/fsutil"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		vpk     string
+		nested  []string
+		err     error
+	}{
+		{"single vpk", []string{"readme.txt", "mod/pak01_dir.vpk", "preview.png"}, "mod/pak01_dir.vpk", nil, nil},
+		{"first by name", []string{"b.vpk", "a.vpk"}, "a.vpk", nil, nil},
+		{"dir with chunk", []string{"pak01_000.vpk", "pak01_dir.vpk"}, "", nil, ErrChunkedVPK},
+		{"dir with chunk, mixed case", []string{"mod/PAK01_DIR.vpk", "mod/pak01_001.VPK"}, "", nil, ErrChunkedVPK},
+		{"chunk without dir", []string{"pak01_000.vpk", "readme.txt"}, "", nil, ErrChunkedVPK},
+		{"chunk of another vpk", []string{"pak02_dir.vpk", "old/pak01_000.vpk"}, "pak02_dir.vpk", nil, nil},
+		{"nested only", []string{"readme.txt", "mod.zip", "inner.tar.gz"}, "", []string{"mod.zip", "inner.tar.gz"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := &extractGuard{dstDir: t.TempDir(), limits: DefaultLimits}
+			var entries []archiveEntry
+			for _, name := range tt.entries {
+				entries = append(entries, archiveEntry{Name: name, Mode: 0644})
+			}
+			entries = append(entries, archiveEntry{Name: "mod/", Mode: fs.ModeDir | 0755})
+
+			vpkName, nested, err := selectEntries(guard, entries)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if vpkName != tt.vpk || !reflect.DeepEqual(nested, tt.nested) {
+				t.Errorf("selectEntries = %q, %v; want %q, %v", vpkName, nested, tt.vpk, tt.nested)
+			}
+		})
+	}
+}
+
+func TestDetectHeader(t *testing.T) {
+	read := func(path string) []byte {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	entries := []testEntry{regular("mod/pak01_dir.vpk", vpkData)}
+	ustar := make([]byte, tarHeaderSize)
+	copy(ustar[tarMagicOffset:], "ustar")
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   archiveFormat
+	}{
+		{"zip", read(writeZip(t, entries)), formatZIP},
+		{"empty zip", []byte("PK\x05\x06" + strings.Repeat("\x00", 18)), formatZIP},
+		{"rar4", read(writeRAR4(t, entries)), formatRAR4},
+		{"rar5", rar5Archive(), formatRAR5},
+		{"7z", read("testdata/traversal.7z"), format7z},
+		{"gzip", read(writeTar(t, entries, true)), formatGzip},
+		{"tar", read(writeTar(t, entries, false)), formatTar},
+		{"tar magic only", ustar, formatTar},
+		{"xz", []byte("\xfd7zXZ\x00\x00\x04"), formatXZ},
+		{"zstd", []byte("\x28\xb5\x2f\xfd\x00"), formatZstd},
+		{"lz4", []byte("\x04\x22\x4d\x18\x64"), formatLZ4},
+		{"vpk", vpkData, formatVPK},
+		{"empty", nil, formatUnknown},
+		{"short", []byte("Ra"), formatUnknown},
+		{"rar without version", []byte("Rar!\x1a\x07\x02\x00"), formatUnknown},
+		{"html error page", []byte("<!DOCTYPE html><title>404</title>"), formatUnknown},
+		{"truncated tar", ustar[:tarMagicOffset+4], formatUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header[:min(len(tt.header), tarHeaderSize)]
+			if got := detectHeader(header); got != tt.want {
+				t.Errorf("detectHeader = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStageBareVPK(t *testing.T) {
+	storeDir := testStore(t)
+	path := writeTemp(t, "pak01_dir.vpk", vpkData)
+
+	// Peek отдаёт голый VPK как есть, и Discard его не удаляет
+	peeked, err := Peek(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peeked.Path != path {
+		t.Errorf("Peek path = %s, want %s", peeked.Path, path)
+	}
+	peeked.Discard()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Discard removed the downloaded vpk: %v", err)
+	}
+
+	// Установка копирует его во временный файл, не трогая исходный
+	staged, err := stage(path, "", storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staged.Discard()
+	data, err := os.ReadFile(staged.Path)
+	if err != nil || !bytes.Equal(data, vpkData) || staged.Path == path {
+		t.Errorf("staged %s = %q, %v; want a copy of %s", staged.Path, data, err, path)
+	}
+
+	// Файл без известной сигнатуры не принимается за VPK по расширению
+	unknown := writeTemp(t, "pak02_dir.vpk", []byte("not a vpk"))
+	if _, err := stage(unknown, "", storeDir); err == nil {
+		t.Error("stage accepted a file with an unknown header")
+	}
+}
+
+// stageByFullExtract повторяет прежний способ установки: весь архив распаковывается
+// во временную папку, и VPK берётся уже оттуда
+func stageByFullExtract(format archiveFormat, archivePath, stagingDir string) (*Staged, error) {
+	a, err := openArchive(format, archivePath, "")
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	tmpDir, err := os.MkdirTemp("", "mod_extract_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	guard, err := newExtractGuard(archivePath, tmpDir, DefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := a.list()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool)
+	for _, e := range entries {
+		if _, err := guard.entry(e.Name, e.Mode); err != nil {
+			return nil, err
+		}
+		if !e.Mode.IsDir() {
+			wanted[e.Name] = true
+		}
+	}
+
+	var vpkPath string
+	err = a.extract(wanted, func(e archiveEntry, r io.Reader) error {
+		outPath, err := fsutil.SafeJoin(tmpDir, e.Name)
+		if err != nil {
+			return err
+		}
+		if vpkPath == "" && strings.HasSuffix(strings.ToLower(e.Name), ".vpk") {
+			vpkPath = outPath
+		}
+		return guard.writeEntry(outPath, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if vpkPath == "" {
+		return nil, errNoVPK
+	}
+	return stageVPKFile(vpkPath, stagingDir)
+}
+
+// BenchmarkStage сравнивает распаковку одного VPK потоком с прежней распаковкой
+// всего архива во временную папку. Архив похож на типичный мод: VPK и несколько
+// скриншотов, которые при установке не нужны.
+func BenchmarkStage(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		data := make([]byte, n)
+		rng.Read(data)
+		return data
+	}
+	entries := []testEntry{regular("mod/pak01_dir.vpk", random(16<<20))}
+	for i := 0; i < 6; i++ {
+		entries = append(entries, regular(fmt.Sprintf("screenshots/%d.png", i), random(4<<20)))
+	}
+	archives := []struct {
+		name   string
+		path   string
+		format archiveFormat
+	}{
+		{"zip", writeZip(b, entries), formatZIP},
+		{"tar", writeTar(b, entries, false), formatTar},
+	}
+	stagingDir := testStore(b)
+
+	methods := []struct {
+		name  string
+		stage func(format archiveFormat, archivePath, stagingDir string) (*Staged, error)
+	}{
+		{"streaming", func(format archiveFormat, archivePath, stagingDir string) (*Staged, error) {
+			return stageFromArchive(format, archivePath, stagingDir, "", 0)
+		}},
+		{"full-extract", stageByFullExtract},
+	}
+	for _, archive := range archives {
+		info, err := os.Stat(archive.path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, m := range methods {
+			b.Run(archive.name+"/"+m.name, func(b *testing.B) {
+				b.SetBytes(info.Size())
+				for i := 0; i < b.N; i++ {
+					staged, err := m.stage(archive.format, archive.path, stagingDir)
+					if err != nil {
+						b.Fatal(err)
+					}
+					staged.Discard()
+				}
+			})
+		}
+	}
+}
+
+func TestPeekKeepsStoreEmpty(t *testing.T) {
+	storeDir := testStore(t)
+	archive := writeZip(t, []testEntry{regular("mod/pak01_dir.vpk", vpkData)})
+
+	staged, err := Peek(archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if staged.SHA256 != "" || strings.HasPrefix(staged.Path, storeDir) {
+		t.Errorf("peeked vpk went to the store: %s", staged.Path)
+	}
+	staged.Discard()
+	if _, err := os.Stat(staged.Path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Discard left %s: %v", staged.Path, err)
+	}
+	if _, err := os.Stat(archive); err != nil {
+		t.Errorf("Peek removed the archive: %v", err)
+	}
+
+	stored, _ := filepath.Glob(filepath.Join(storeDir, "*", "*.vpk"))
+	if len(stored) > 0 {
+		t.Errorf("store is not empty after Peek: %v", stored)
+	}
+}
+
+func TestStageAdoptsValidVPK(t *testing.T) {
+	storeDir := testStore(t)
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "readme.txt"), []byte("mod"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vpkPath := filepath.Join(t.TempDir(), "pak01_dir.vpk")
+	if err := vpk.PackDir(src, vpkPath); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(vpkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := writeZip(t, []testEntry{regular("pak01_dir.vpk", data)})
+	staged, err := Stage(archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staged.Discard()
+	if staged.SHA256 == "" || !strings.HasPrefix(staged.Path, storeDir) {
+		t.Errorf("staged vpk is not in the store: %+v", staged)
+	}
+	if _, err := os.Stat(archive); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stage kept the archive: %v", err)
+	}
+
+	// Битый VPK отклоняется и в хранилище не попадает
+	broken := writeZip(t, []testEntry{regular("pak01_dir.vpk", data[:len(data)-10])})
+	if _, err := Stage(broken, ""); !errors.Is(err, ErrInvalidVPK) {
+		t.Fatalf("Stage(broken) error = %v, want %v", err, ErrInvalidVPK)
+	}
+	stored, _ := filepath.Glob(filepath.Join(storeDir, "*", "*.vpk"))
+	if len(stored) != 1 {
+		t.Errorf("store holds %v, want only the valid vpk", stored)
+	}
+}
@@ -0,0 +1,215 @@
+package extractfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bodgit/sevenzip"
+)
+
+// PasswordError возвращается, когда архив зашифрован и пароль не указан или не подошёл.
+// GUI по нему спрашивает пароль и повторяет установку.
+type PasswordError struct {
+	Archive string
+	Wrong   bool  // пароль был указан, но не подошёл
+	Err     error // исходная ошибка библиотеки распаковки, если есть
+}
+
+func (e *PasswordError) Error() string {
+	if e.Wrong {
+		return fmt.Sprintf("wrong password for archive %s", e.Archive)
+	}
+	return fmt.Sprintf("archive %s is password protected", e.Archive)
+}
+
+func (e *PasswordError) Unwrap() error {
+	return e.Err
+}
+
+// passwordError превращает ошибку распаковки зашифрованного архива в *PasswordError.
+// Ошибки проверок безопасности остаются как есть.
+func passwordError(archivePath, password string, err error) error {
+	if errors.Is(err, ErrUnsafePath) || errors.Is(err, ErrUnsafeEntry) || errors.Is(err, ErrArchiveLimit) {
+		return err
+	}
+	return &PasswordError{Archive: archivePath, Wrong: password != "", Err: err}
+}
+
+// is7zEncrypted сообщает, что ошибка 7z вызвана шифрованием
+func is7zEncrypted(err error) bool {
+	var readErr *sevenzip.ReadError
+	return errors.As(err, &readErr) && readErr.Encrypted
+}
+
+// Флаги и типы заголовков RAR, нужные для определения шифрования
+const (
+	rar4BlockMain       = 0x73
+	rar4BlockFile       = 0x74
+	rar4BlockService    = 0x7a
+	rar4MainEncrypted   = 0x0080 // заголовки зашифрованы
+	rar4FileEncrypted   = 0x0004
+	rar4LongBlock       = 0x8000
+	rar4FileLargeSize   = 0x0100 // размер данных больше 4 ГБ, старшие 32 бита — в HIGH_PACK_SIZE
+	rar4HighPackSizeOff = 32     // смещение HIGH_PACK_SIZE от начала заголовка файла
+	rar5BlockFile       = 2
+	rar5BlockEncryption = 4
+	rar5BlockEnd        = 5
+	rar5HasExtra        = 0x0001
+	rar5HasData         = 0x0002
+	rar5ExtraEncryption = 1
+
+	maxRARHeaderBlocks = 64
+)
+
+// rarEncrypted читает заголовки RAR до первого файла и сообщает, зашифрован ли архив.
+// rardecode не различает «нет пароля» и «битые данные», поэтому проверяем сами.
+func rarEncrypted(path string) (bool, error) {
+	format, err := detectFormat(path)
+	if err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open rar: %w", err)
+	}
+	defer f.Close()
+
+	switch format {
+	case formatRAR4:
+		return rar4Encrypted(f)
+	case formatRAR5:
+		return rar5Encrypted(f)
+	}
+	return false, nil
+}
+
+func rar4Encrypted(f *os.File) (bool, error) {
+	pos := int64(7) // длина сигнатуры RAR 1.5-4.x
+	head := make([]byte, 11)
+	for i := 0; i < maxRARHeaderBlocks; i++ {
+		n, err := f.ReadAt(head, pos)
+		if n < 7 {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to read rar header: %w", err)
+		}
+		blockType := head[2]
+		flags := binary.LittleEndian.Uint16(head[3:5])
+		size := int64(binary.LittleEndian.Uint16(head[5:7]))
+
+		switch blockType {
+		case rar4BlockMain:
+			if flags&rar4MainEncrypted != 0 {
+				return true, nil
+			}
+		case rar4BlockFile:
+			return flags&rar4FileEncrypted != 0, nil
+		}
+
+		if flags&rar4LongBlock != 0 && n >= 11 {
+			size += int64(binary.LittleEndian.Uint32(head[7:11]))
+		}
+		if blockType == rar4BlockService && flags&rar4FileLargeSize != 0 {
+			var high [4]byte
+			if _, err := f.ReadAt(high[:], pos+rar4HighPackSizeOff); err != nil {
+				return false, fmt.Errorf("failed to read rar header: %w", err)
+			}
+			size += int64(binary.LittleEndian.Uint32(high[:])) << 32
+		}
+		if size < 7 {
+			return false, errors.New("corrupt rar header")
+		}
+		pos += size
+	}
+	return false, nil
+}
+
+func rar5Encrypted(f *os.File) (bool, error) {
+	if _, err := f.Seek(8, io.SeekStart); err != nil { // длина сигнатуры RAR 5.0
+		return false, err
+	}
+	br := bufio.NewReader(f)
+	for i := 0; i < maxRARHeaderBlocks; i++ {
+		if _, err := br.Discard(4); err != nil { // CRC32 заголовка
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+		headerSize, err := binary.ReadUvarint(br)
+		if err != nil {
+			return false, err
+		}
+		if headerSize == 0 || headerSize > 2<<20 {
+			return false, errors.New("corrupt rar header")
+		}
+		header := make([]byte, headerSize)
+		if _, err := io.ReadFull(br, header); err != nil {
+			return false, err
+		}
+
+		b := rarBuf(header)
+		blockType := b.uvarint()
+		flags := b.uvarint()
+		var extraSize, dataSize uint64
+		if flags&rar5HasExtra != 0 {
+			extraSize = b.uvarint()
+		}
+		if flags&rar5HasData != 0 {
+			dataSize = b.uvarint()
+		}
+
+		switch blockType {
+		case rar5BlockEncryption:
+			return true, nil
+		case rar5BlockFile:
+			if extraSize > headerSize {
+				return false, errors.New("corrupt rar header")
+			}
+			return rar5ExtraEncrypted(header[headerSize-extraSize:]), nil
+		case rar5BlockEnd:
+			return false, nil
+		}
+
+		if _, err := br.Discard(int(dataSize)); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// rar5ExtraEncrypted ищет запись шифрования в дополнительной области заголовка файла
+func rar5ExtraEncrypted(extra []byte) bool {
+	b := rarBuf(extra)
+	for len(b) > 0 {
+		size := b.uvarint()
+		if size == 0 || size > uint64(len(b)) {
+			return false
+		}
+		record := rarBuf(b[:size])
+		if record.uvarint() == rar5ExtraEncryption {
+			return true
+		}
+		b = b[size:]
+	}
+	return false
+}
+
+// rarBuf — срез заголовка с чтением vint-чисел RAR5
+type rarBuf []byte
+
+func (b *rarBuf) uvarint() uint64 {
+	v, n := binary.Uvarint(*b)
+	if n <= 0 {
+		*b = nil
+		return 0
+	}
+	*b = (*b)[n:]
+	return v
+}
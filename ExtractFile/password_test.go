@@ -0,0 +1,194 @@
+package extractfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"testing"
+)
+
+// rar4Archive собирает RAR 4.x из готовых блоков после сигнатуры
+func rar4Archive(blocks ...func(buf *bytes.Buffer)) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("Rar!\x1a\x07\x00")
+	for _, block := range blocks {
+		block(&buf)
+	}
+	return buf.Bytes()
+}
+
+func rar4Main(flags uint16) func(buf *bytes.Buffer) {
+	return func(buf *bytes.Buffer) { writeRAR4Block(buf, rar4BlockMain, flags, make([]byte, 6), nil) }
+}
+
+// rar4File пишет заголовок файла или служебный блок с данными data. Если high не
+// ноль, в заголовок добавляется HIGH_PACK_SIZE, а сами данные не пишутся: они
+// должны были бы занимать больше 4 ГБ.
+func rar4File(blockType byte, flags uint16, high uint32, data []byte) func(buf *bytes.Buffer) {
+	return func(buf *bytes.Buffer) {
+		head := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+		head = binary.LittleEndian.AppendUint32(head, uint32(len(data)))
+		head = append(head, 3)
+		head = binary.LittleEndian.AppendUint32(head, crc32.ChecksumIEEE(data))
+		head = binary.LittleEndian.AppendUint32(head, 0x21<<16)
+		head = append(head, 20, 0x30)
+		head = binary.LittleEndian.AppendUint16(head, 2)
+		head = binary.LittleEndian.AppendUint32(head, 0x81a4)
+		if flags&rar4FileLargeSize != 0 {
+			head = binary.LittleEndian.AppendUint32(head, high) // HIGH_PACK_SIZE
+			head = binary.LittleEndian.AppendUint32(head, 0)    // HIGH_UNP_SIZE
+		}
+		head = append(head, "CM"...)
+		if high != 0 {
+			data = nil
+		}
+		writeRAR4Block(buf, blockType, flags|rar4LongBlock, head, data)
+	}
+}
+
+// rar5Block собирает блок RAR5: CRC32 (не проверяется), размер заголовка, тип,
+// флаги, размеры доп. области и данных, поля fields, доп. область extra и данные
+func rar5Block(blockType, flags uint64, fields, extra []byte, dataSize int) []byte {
+	header := binary.AppendUvarint(nil, blockType)
+	header = binary.AppendUvarint(header, flags)
+	if flags&rar5HasExtra != 0 {
+		header = binary.AppendUvarint(header, uint64(len(extra)))
+	}
+	if flags&rar5HasData != 0 {
+		header = binary.AppendUvarint(header, uint64(dataSize))
+	}
+	header = append(append(header, fields...), extra...)
+
+	block := make([]byte, 4)
+	block = binary.AppendUvarint(block, uint64(len(header)))
+	block = append(block, header...)
+	return append(block, make([]byte, dataSize)...)
+}
+
+func rar5Archive(blocks ...[]byte) []byte {
+	data := []byte("Rar!\x1a\x07\x01\x00")
+	data = append(data, rar5Block(1, 0, []byte{0}, nil, 0)...) // главный заголовок
+	for _, block := range blocks {
+		data = append(data, block...)
+	}
+	return data
+}
+
+func TestRAREncrypted(t *testing.T) {
+	// Поля заголовка файла RAR5 парсеру не нужны, хватает заполнителя
+	fileFields := []byte{0, 4, 0, 0, 2, 'C', 'M'}
+	encryption := []byte{3, rar5ExtraEncryption, 0, 0} // размер, тип, версия, флаги
+	other := []byte{2, 3, 0}                           // запись времени файла
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"rar4 plain", rar4Archive(rar4Main(0), rar4File(rar4BlockFile, 0, 0, []byte("data"))), false},
+		{"rar4 encrypted headers", rar4Archive(rar4Main(rar4MainEncrypted)), true},
+		{"rar4 encrypted file", rar4Archive(rar4Main(0), rar4File(rar4BlockFile, rar4FileEncrypted, 0, []byte("data"))), true},
+		{"rar4 service block before file", rar4Archive(
+			rar4Main(0),
+			rar4File(rar4BlockService, 0, 0, []byte("comment")),
+			rar4File(rar4BlockFile, rar4FileEncrypted, 0, []byte("data")),
+		), true},
+		{"rar4 large service block", rar4Archive(
+			rar4Main(0),
+			rar4File(rar4BlockService, rar4FileLargeSize, 0, []byte("comment")),
+			rar4File(rar4BlockFile, rar4FileEncrypted, 0, []byte("data")),
+		), true},
+		// Данные служебного блока больше 4 ГБ: следующий заголовок лежит за концом
+		// файла, а не сразу после младших 32 бит размера
+		{"rar4 service block over 4 GiB", rar4Archive(
+			rar4Main(0),
+			rar4File(rar4BlockService, rar4FileLargeSize, 1, []byte("comment")),
+			rar4File(rar4BlockFile, rar4FileEncrypted, 0, []byte("data")),
+		), false},
+		{"rar4 end of archive", rar4Archive(rar4Main(0)), false},
+
+		{"rar5 plain", rar5Archive(rar5Block(rar5BlockFile, rar5HasExtra|rar5HasData, fileFields, other, 4)), false},
+		{"rar5 no extra", rar5Archive(rar5Block(rar5BlockFile, rar5HasData, fileFields, nil, 4)), false},
+		{"rar5 encrypted file", rar5Archive(rar5Block(rar5BlockFile, rar5HasExtra|rar5HasData, fileFields, append(other, encryption...), 4)), true},
+		{"rar5 encrypted headers", rar5Archive(rar5Block(rar5BlockEncryption, 0, []byte{0, 0, 15}, nil, 0)), true},
+		{"rar5 service block before file", rar5Archive(
+			rar5Block(3, rar5HasData, fileFields, nil, 100),
+			rar5Block(rar5BlockFile, rar5HasExtra|rar5HasData, fileFields, encryption, 4),
+		), true},
+		{"rar5 end of archive", rar5Archive(
+			rar5Block(rar5BlockEnd, 0, []byte{0}, nil, 0),
+			rar5Block(rar5BlockFile, rar5HasExtra, fileFields, encryption, 0),
+		), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rarEncrypted(writeTemp(t, "test.rar", tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("rarEncrypted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRAREncryptedCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"rar4 short block", rar4Archive(func(buf *bytes.Buffer) {
+			buf.Write([]byte{0, 0, 0x7a, 0, 0, 3, 0}) // размер блока 3 меньше заголовка
+		})},
+		{"rar5 zero header size", append([]byte("Rar!\x1a\x07\x01\x00"), 0, 0, 0, 0, 0)},
+		{"rar5 huge header size", binary.AppendUvarint([]byte("Rar!\x1a\x07\x01\x00\x00\x00\x00\x00"), 1<<30)},
+		{"rar5 truncated header", append([]byte("Rar!\x1a\x07\x01\x00"), 0, 0, 0, 0, 20, 1)},
+		{"rar5 extra larger than header", rar5Archive(func() []byte {
+			block := rar5Block(rar5BlockFile, rar5HasExtra, []byte{0}, nil, 0)
+			block[7] = 100 // размер доп. области
+			return block
+		}())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := rarEncrypted(writeTemp(t, "test.rar", tt.data)); err == nil {
+				t.Fatal("rarEncrypted accepted a corrupt header")
+			}
+		})
+	}
+}
+
+func TestPasswordError(t *testing.T) {
+	cause := errors.New("checksum error")
+	tests := []struct {
+		password string
+		err      error
+		wrong    bool
+		message  string
+	}{
+		{"", cause, false, "archive mod.rar is password protected"},
+		{"secret", cause, true, "wrong password for archive mod.rar"},
+	}
+	for _, tt := range tests {
+		err := passwordError("mod.rar", tt.password, tt.err)
+		var pwErr *PasswordError
+		if !errors.As(err, &pwErr) {
+			t.Fatalf("passwordError(%q) = %v, want *PasswordError", tt.password, err)
+		}
+		if pwErr.Wrong != tt.wrong || err.Error() != tt.message || !errors.Is(err, cause) {
+			t.Errorf("passwordError(%q) = %+v (%q)", tt.password, pwErr, err)
+		}
+	}
+
+	// Ошибки проверок безопасности не выдаются за неверный пароль
+	for _, safety := range []error{ErrUnsafePath, ErrUnsafeEntry, ErrArchiveLimit} {
+		err := passwordError("mod.rar", "secret", fmt.Errorf("entry x: %w", safety))
+		var pwErr *PasswordError
+		if errors.As(err, &pwErr) || !errors.Is(err, safety) {
+			t.Errorf("passwordError(%v) = %v", safety, err)
+		}
+	}
+}
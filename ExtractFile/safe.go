@@ -0,0 +1,132 @@
+package extractfile
+
+import (
+	"DeadlockHelper/internal/fsutil"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Ограничения распаковки по умолчанию. VPK-паки модов бывают по несколько
+// гигабайт, поэтому общий лимит берём с запасом.
+const (
+	defaultMaxTotalSize = 16 << 30 // 16 ГиБ распакованных данных
+	defaultMaxEntries   = 20000
+	defaultMaxRatio     = 200 // распакованный размер / размер архива
+
+	// ratioGrace — объём, до которого коэффициент сжатия не проверяется:
+	// крошечные архивы с текстом легко сжимаются сильнее лимита.
+	ratioGrace = 16 << 20
+)
+
+var (
+	// ErrUnsafePath возвращается для записей, которые пытаются выйти за пределы папки распаковки
+	ErrUnsafePath = fsutil.ErrUnsafePath
+	// ErrUnsafeEntry возвращается для символических ссылок, устройств и других нестандартных записей
+	ErrUnsafeEntry = errors.New("unsupported entry type in archive")
+	// ErrArchiveLimit возвращается, когда архив превышает лимиты распаковки (похоже на архивную бомбу)
+	ErrArchiveLimit = errors.New("archive exceeds extraction limits")
+)
+
+// Limits задаёт ограничения на распаковку одного архива
+type Limits struct {
+	MaxTotalSize int64 // суммарный распакованный размер
+	MaxEntries   int   // количество записей в архиве
+	MaxRatio     int64 // максимальный коэффициент сжатия относительно размера архива
+}
+
+// DefaultLimits — ограничения, применяемые при установке модов
+var DefaultLimits = Limits{
+	MaxTotalSize: defaultMaxTotalSize,
+	MaxEntries:   defaultMaxEntries,
+	MaxRatio:     defaultMaxRatio,
+}
+
+// extractGuard проверяет записи архива и считает распакованные байты
+type extractGuard struct {
+	dstDir      string
+	limits      Limits
+	archiveSize int64
+	entries     int
+	total       int64
+}
+
+// newExtractGuard создаёт проверку для распаковки архива archivePath в папку dstDir
+func newExtractGuard(archivePath, dstDir string, limits Limits) (*extractGuard, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+	return &extractGuard{
+		dstDir:      dstDir,
+		limits:      limits,
+		archiveSize: info.Size(),
+	}, nil
+}
+
+// entry проверяет очередную запись архива и возвращает безопасный путь для неё
+func (g *extractGuard) entry(name string, mode fs.FileMode) (string, error) {
+	g.entries++
+	if g.limits.MaxEntries > 0 && g.entries > g.limits.MaxEntries {
+		return "", fmt.Errorf("%w: more than %d entries", ErrArchiveLimit, g.limits.MaxEntries)
+	}
+	if !mode.IsDir() && !mode.IsRegular() {
+		return "", fmt.Errorf("%w: %s (%s)", ErrUnsafeEntry, name, mode.Type())
+	}
+	return fsutil.SafeJoin(g.dstDir, name)
+}
+
+// copy копирует данные записи из src в dst с учётом лимитов размера и сжатия
+func (g *extractGuard) copy(dst io.Writer, src io.Reader) (int64, error) {
+	return io.Copy(dst, &guardedReader{r: src, g: g})
+}
+
+// add учитывает n распакованных байт и проверяет лимиты
+func (g *extractGuard) add(n int64) error {
+	g.total += n
+	if g.limits.MaxTotalSize > 0 && g.total > g.limits.MaxTotalSize {
+		return fmt.Errorf("%w: more than %d bytes uncompressed", ErrArchiveLimit, g.limits.MaxTotalSize)
+	}
+	if g.limits.MaxRatio > 0 && g.total > ratioGrace && g.total > g.archiveSize*g.limits.MaxRatio {
+		return fmt.Errorf("%w: compression ratio above %d", ErrArchiveLimit, g.limits.MaxRatio)
+	}
+	return nil
+}
+
+// guardedReader прерывает чтение, как только превышен один из лимитов
+type guardedReader struct {
+	r io.Reader
+	g *extractGuard
+}
+
+func (gr *guardedReader) Read(p []byte) (int, error) {
+	n, err := gr.r.Read(p)
+	if n > 0 {
+		if limitErr := gr.g.add(int64(n)); limitErr != nil {
+			return n, limitErr
+		}
+	}
+	return n, err
+}
+
+// writeEntry создаёт файл outPath и записывает в него данные записи через guard
+func (g *extractGuard) writeEntry(outPath string, src io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for file %s: %w", outPath, err)
+	}
+
+	// O_EXCL: повторяющееся имя в архиве не должно перезаписывать уже распакованный файл
+	dstFile, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", outPath, err)
+	}
+
+	if _, err := g.copy(dstFile, src); err != nil {
+		dstFile.Close()
+		return fmt.Errorf("failed to copy file %s: %w", outPath, err)
+	}
+	return dstFile.Close()
+}
@@ -0,0 +1,333 @@
+package extractfile
+
+import (
+	store "DeadlockHelper/Store"
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testEntry — запись архива, который собирается в тесте
+type testEntry struct {
+	name string
+	mode fs.FileMode
+	data []byte
+	link string // цель символической ссылки
+}
+
+func regular(name string, data []byte) testEntry {
+	return testEntry{name: name, mode: 0644, data: data}
+}
+
+// writeZip собирает ZIP из entries во временной папке теста
+func writeZip(t testing.TB, entries []testEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := e.data
+		if e.mode&fs.ModeSymlink != 0 {
+			data = []byte(e.link)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeTemp(t, "test.zip", buf.Bytes())
+}
+
+// writeTar собирает tar из entries, при compress — сжатый gzip
+func writeTar(t testing.TB, entries []testEntry, compress bool) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var gw *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if compress {
+		gw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gw)
+	}
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), Size: int64(len(e.data))}
+		switch {
+		case e.mode.IsDir():
+			hdr.Typeflag, hdr.Size = tar.TypeDir, 0
+		case e.mode&fs.ModeSymlink != 0:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+		case e.mode&fs.ModeCharDevice != 0:
+			hdr.Typeflag, hdr.Size = tar.TypeChar, 0
+		case e.mode&fs.ModeNamedPipe != 0:
+			hdr.Typeflag, hdr.Size = tar.TypeFifo, 0
+		default:
+			hdr.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.data[:hdr.Size]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	name := "test.tar"
+	if compress {
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		name = "test.tar.gz"
+	}
+	return writeTemp(t, name, buf.Bytes())
+}
+
+// writeRAR4 собирает RAR 4.x без сжатия (метод «store»). Утилиты rar в тестовом
+// окружении нет, а формат заголовков простой.
+func writeRAR4(t testing.TB, entries []testEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("Rar!\x1a\x07\x00")
+	writeRAR4Block(&buf, rar4BlockMain, 0, make([]byte, 6), nil)
+
+	for _, e := range entries {
+		data := e.data
+		attr := uint32(0x8000) | uint32(e.mode.Perm()) // S_IFREG
+		if e.mode&fs.ModeSymlink != 0 {
+			data, attr = []byte(e.link), 0xa000|uint32(e.mode.Perm()) // S_IFLNK
+		}
+		head := binary.LittleEndian.AppendUint32(nil, uint32(len(data))) // упакованный размер
+		head = binary.LittleEndian.AppendUint32(head, uint32(len(data))) // распакованный размер
+		head = append(head, 3)                                           // создан в Unix
+		head = binary.LittleEndian.AppendUint32(head, crc32.ChecksumIEEE(data))
+		head = binary.LittleEndian.AppendUint32(head, 0x21<<16) // 1 января 1980
+		head = append(head, 20, 0x30)                           // версия 2.0, без сжатия
+		head = binary.LittleEndian.AppendUint16(head, uint16(len(e.name)))
+		head = binary.LittleEndian.AppendUint32(head, attr)
+		head = append(head, e.name...)
+		writeRAR4Block(&buf, rar4BlockFile, rar4LongBlock, head, data)
+	}
+	writeRAR4Block(&buf, 0x7b, 0x4000, nil, nil) // конец архива
+	return writeTemp(t, "test.rar", buf.Bytes())
+}
+
+// writeRAR4Block пишет заголовок блока RAR 4.x с контрольной суммой и данные после него
+func writeRAR4Block(buf *bytes.Buffer, blockType byte, flags uint16, head, data []byte) {
+	block := []byte{blockType}
+	block = binary.LittleEndian.AppendUint16(block, flags)
+	block = binary.LittleEndian.AppendUint16(block, uint16(7+len(head)))
+	block = append(block, head...)
+	binary.Write(buf, binary.LittleEndian, uint16(crc32.ChecksumIEEE(block)))
+	buf.Write(block)
+	buf.Write(data)
+}
+
+func writeTemp(t testing.TB, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testStore переносит домашнюю папку, а с ней и хранилище, во временную папку теста
+// и возвращает папку хранилища
+func testStore(t testing.TB) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir, err := store.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// stageTest извлекает VPK из архива так же, как установка мода, но с
+// хранилищем во временной папке
+func stageTest(t testing.TB, archivePath string) (*Staged, error) {
+	t.Helper()
+	stagingDir := testStore(t)
+	format, err := detectFormat(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stageFromArchive(format, archivePath, stagingDir, "", 0)
+}
+
+var vpkData = []byte("\x34\x12\xaa\x55vpk")
+
+func TestStageSafeArchives(t *testing.T) {
+	entries := []testEntry{
+		regular("readme.txt", []byte("readme")),
+		{name: "mod/", mode: fs.ModeDir | 0755},
+		regular("mod/pak01_dir.vpk", vpkData),
+	}
+	archives := map[string]string{
+		"zip":    writeZip(t, entries),
+		"tar":    writeTar(t, entries, false),
+		"tar.gz": writeTar(t, entries, true),
+		"rar":    writeRAR4(t, []testEntry{entries[0], entries[2]}),
+	}
+
+	for name, path := range archives {
+		t.Run(name, func(t *testing.T) {
+			staged, err := stageTest(t, path)
+			if err != nil {
+				t.Fatalf("stage: %v", err)
+			}
+			defer staged.Discard()
+			data, err := os.ReadFile(staged.Path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, vpkData) {
+				t.Errorf("staged data = %q, want %q", data, vpkData)
+			}
+		})
+	}
+}
+
+func TestStageUnsafeArchives(t *testing.T) {
+	traversal := []testEntry{regular("mod/pak01_dir.vpk", vpkData), regular("../../evil.vpk", vpkData)}
+	backslash := []testEntry{regular("..\\evil.vpk", vpkData)}
+	absolute := []testEntry{regular("/tmp/evil.vpk", vpkData)}
+	symlink := []testEntry{
+		{name: "mod/pak01_dir.vpk", mode: fs.ModeSymlink | 0777, link: "/etc/passwd"},
+	}
+	device := []testEntry{{name: "mod/pak01_dir.vpk", mode: fs.ModeDevice | fs.ModeCharDevice | 0644}}
+	fifo := []testEntry{{name: "pipe.vpk", mode: fs.ModeNamedPipe | 0644}}
+
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) string
+		want    error
+	}{
+		{"zip traversal", func(t *testing.T) string { return writeZip(t, traversal) }, ErrUnsafePath},
+		{"zip backslash traversal", func(t *testing.T) string { return writeZip(t, backslash) }, ErrUnsafePath},
+		{"zip absolute", func(t *testing.T) string { return writeZip(t, absolute) }, ErrUnsafePath},
+		{"zip drive letter", func(t *testing.T) string { return writeZip(t, []testEntry{regular("C:\\evil.vpk", vpkData)}) }, ErrUnsafePath},
+		{"zip symlink", func(t *testing.T) string { return writeZip(t, symlink) }, ErrUnsafeEntry},
+		{"zip device", func(t *testing.T) string { return writeZip(t, device) }, ErrUnsafeEntry},
+		{"tar traversal", func(t *testing.T) string { return writeTar(t, traversal, false) }, ErrUnsafePath},
+		{"tar absolute", func(t *testing.T) string { return writeTar(t, absolute, false) }, ErrUnsafePath},
+		{"tar symlink", func(t *testing.T) string { return writeTar(t, symlink, false) }, ErrUnsafeEntry},
+		{"tar device", func(t *testing.T) string { return writeTar(t, device, false) }, ErrUnsafeEntry},
+		{"tar fifo", func(t *testing.T) string { return writeTar(t, fifo, false) }, ErrUnsafeEntry},
+		{"tar.gz traversal", func(t *testing.T) string { return writeTar(t, traversal, true) }, ErrUnsafePath},
+		{"tar.gz symlink", func(t *testing.T) string { return writeTar(t, symlink, true) }, ErrUnsafeEntry},
+		{"rar traversal", func(t *testing.T) string { return writeRAR4(t, traversal) }, ErrUnsafePath},
+		{"rar absolute", func(t *testing.T) string { return writeRAR4(t, absolute) }, ErrUnsafePath},
+		{"rar symlink", func(t *testing.T) string { return writeRAR4(t, symlink) }, ErrUnsafeEntry},
+		// 7z собраны bsdtar: bsdtar -P --format 7zip -cf <имя>.7z @<список>
+		{"7z traversal", func(t *testing.T) string { return "testdata/traversal.7z" }, ErrUnsafePath},
+		{"7z absolute", func(t *testing.T) string { return "testdata/absolute.7z" }, ErrUnsafePath},
+		{"7z symlink", func(t *testing.T) string { return "testdata/symlink.7z" }, ErrUnsafeEntry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.archive(t)
+			staged, err := stageTest(t, path)
+			if err == nil {
+				staged.Discard()
+				t.Fatalf("stage succeeded, want %v", tt.want)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("stage error = %v, want %v", err, tt.want)
+			}
+			// Ничего не должно появиться рядом с архивом
+			for _, name := range []string{"evil.vpk", "../evil.vpk", "../../evil.vpk"} {
+				if _, err := os.Lstat(filepath.Join(filepath.Dir(path), name)); err == nil {
+					t.Errorf("%s was written outside the staging dir", name)
+				}
+			}
+		})
+	}
+}
+
+func TestStageEntryLimit(t *testing.T) {
+	entries := make([]testEntry, DefaultLimits.MaxEntries+1)
+	for i := range entries {
+		entries[i] = regular(fmt.Sprintf("junk/%05d.txt", i), nil)
+	}
+	entries[len(entries)-1] = regular("pak01_dir.vpk", vpkData)
+
+	_, err := stageTest(t, writeZip(t, entries))
+	if !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("stage error = %v, want %v", err, ErrArchiveLimit)
+	}
+}
+
+func TestStageRatioLimit(t *testing.T) {
+	// Нули сжимаются примерно в тысячу раз, лимит — 200
+	bomb := []testEntry{regular("pak01_dir.vpk", make([]byte, 2*ratioGrace))}
+	archives := map[string]string{
+		"zip":    writeZip(t, bomb),
+		"tar.gz": writeTar(t, bomb, true),
+	}
+	for name, path := range archives {
+		t.Run(name, func(t *testing.T) {
+			_, err := stageTest(t, path)
+			if !errors.Is(err, ErrArchiveLimit) {
+				t.Fatalf("stage error = %v, want %v", err, ErrArchiveLimit)
+			}
+			stagingDir, _ := store.Dir()
+			leftovers, _ := filepath.Glob(filepath.Join(stagingDir, ".stage-*"))
+			if len(leftovers) > 0 {
+				t.Errorf("partial files left in staging dir: %v", leftovers)
+			}
+		})
+	}
+}
+
+func TestGuardTotalSizeLimit(t *testing.T) {
+	path := writeZip(t, []testEntry{regular("pak01_dir.vpk", bytes.Repeat([]byte("x"), 1000))})
+	guard, err := newExtractGuard(path, t.TempDir(), Limits{MaxTotalSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outPath, err := guard.entry("pak01_dir.vpk", 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = guard.writeEntry(outPath, bytes.NewReader(make([]byte, 1000)))
+	if !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("writeEntry error = %v, want %v", err, ErrArchiveLimit)
+	}
+}
+
+func TestGuardDuplicateEntry(t *testing.T) {
+	path := writeZip(t, nil)
+	guard, err := newExtractGuard(path, t.TempDir(), DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		outPath, err := guard.entry("nested.zip", 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = guard.writeEntry(outPath, bytes.NewReader([]byte{byte(i)}))
+		if (err == nil) != want {
+			t.Fatalf("write %d: error = %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package extractfile
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// decompressor открывает распакованный поток поверх сжатого файла
+type decompressor func(r io.Reader) (io.ReadCloser, error)
+
+var decompressors = map[archiveFormat]decompressor{
+	formatGzip: func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	formatXZ: func(r io.Reader) (io.ReadCloser, error) {
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	},
+	formatZstd: func(r io.Reader) (io.ReadCloser, error) {
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	},
+	formatLZ4: func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(lz4.NewReader(r)), nil
+	},
+}
+
+// streamArchive — tar или сжатый поток (gzip/xz/zstd/lz4). Такие форматы читаются
+// только последовательно, поэтому каждый проход заново открывает файл. Сжатый поток
+// без tar внутри считается архивом из одного файла.
+type streamArchive struct {
+	path   string
+	format archiveFormat
+}
+
+// walk проходит по записям потока, вызывая fn для каждой
+func (a *streamArchive) walk(fn func(e archiveEntry, r io.Reader) error) error {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", a.format, err)
+	}
+	defer file.Close()
+
+	var stream io.Reader = file
+	if a.format != formatTar {
+		rc, err := decompressors[a.format](bufio.NewReader(file))
+		if err != nil {
+			return fmt.Errorf("failed to create %s reader: %w", a.format, err)
+		}
+		defer rc.Close()
+		stream = rc
+	}
+
+	br := bufio.NewReaderSize(stream, tarHeaderSize)
+	header, err := br.Peek(tarHeaderSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return fmt.Errorf("failed to read %s stream: %w", a.format, err)
+	}
+	switch detectHeader(header) {
+	case formatTar:
+		return walkTar(br, fn)
+	case formatVPK:
+		name := compressedFileName(a.path)
+		if !strings.HasSuffix(strings.ToLower(name), ".vpk") {
+			name += ".vpk"
+		}
+		return fn(archiveEntry{Name: name, Mode: 0644}, br)
+	}
+	if a.format == formatTar {
+		return errors.New("corrupt tar header")
+	}
+
+	name := compressedFileName(a.path)
+	if gz, ok := stream.(*gzip.Reader); ok && gz.Name != "" {
+		name = filepath.Base(strings.ReplaceAll(gz.Name, "\\", "/"))
+	}
+	return fn(archiveEntry{Name: name, Mode: 0644}, br)
+}
+
+func (a *streamArchive) list() ([]archiveEntry, error) {
+	var entries []archiveEntry
+	err := a.walk(func(e archiveEntry, _ io.Reader) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+func (a *streamArchive) extract(wanted map[string]bool, fn func(e archiveEntry, r io.Reader) error) error {
+	return a.walk(func(e archiveEntry, r io.Reader) error {
+		if !wanted[e.Name] {
+			return nil
+		}
+		return fn(e, r)
+	})
+}
+
+func (a *streamArchive) Close() error {
+	return nil
+}
+
+// walkTar проходит по записям tar потока. Ссылки и устройства отдаются с их типом
+// в Mode, чтобы проверка безопасности отклонила архив.
+func walkTar(r io.Reader, fn func(e archiveEntry, r io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tar: %w", err)
+		}
+
+		mode := hdr.FileInfo().Mode()
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg, tar.TypeDir:
+		case tar.TypeLink:
+			// жёсткая ссылка выглядит как обычный файл, но данных у неё нет
+			mode |= fs.ModeIrregular
+		}
+
+		if err := fn(archiveEntry{Name: hdr.Name, Mode: mode}, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// compressedFileName убирает расширение сжатия из имени файла: mod.vpk.xz -> mod.vpk
+func compressedFileName(archivePath string) string {
+	name := filepath.Base(archivePath)
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".gz", ".xz", ".zst", ".lz4":
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	case ".tgz", ".txz":
+		return strings.TrimSuffix(name, filepath.Ext(name)) + ".tar"
+	}
+	return name
+}
@@ -0,0 +1,48 @@
+package extractfile
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Схемы имён многотомных архивов:
+//
+//	mod.part1.rar, mod.part2.rar, ...  (RAR 3+)
+//	mod.rar, mod.r00, mod.r01, ...     (старая схема RAR)
+//	mod.7z.001, mod.7z.002, ...        (7z)
+var (
+	rarPartRe  = regexp.MustCompile(`(?i)^(.+)\.part\d+\.rar$`)
+	rarOldRe   = regexp.MustCompile(`(?i)^(.+)\.rar$`)
+	sevenZipRe = regexp.MustCompile(`(?i)^(.+\.7z)\.\d{3}$`)
+)
+
+// archiveVolumes возвращает все тома многотомного архива, лежащие рядом с первым томом.
+// Для обычного архива возвращается только он сам.
+func archiveVolumes(archivePath string) []string {
+	dir, name := filepath.Split(archivePath)
+
+	var pattern *regexp.Regexp
+	if m := rarPartRe.FindStringSubmatch(name); m != nil {
+		pattern = regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(m[1]) + `\.part\d+\.rar$`)
+	} else if m := sevenZipRe.FindStringSubmatch(name); m != nil {
+		pattern = regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(m[1]) + `\.\d{3}$`)
+	} else if m := rarOldRe.FindStringSubmatch(name); m != nil {
+		pattern = regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(m[1]) + `\.r\d{2}$`)
+	}
+
+	volumes := []string{archivePath}
+	if pattern == nil {
+		return volumes
+	}
+	entries, err := os.ReadDir(filepath.Clean(dir + "."))
+	if err != nil {
+		return volumes
+	}
+	for _, e := range entries {
+		if !e.IsDir() && e.Name() != name && pattern.MatchString(e.Name()) {
+			volumes = append(volumes, filepath.Join(dir, e.Name()))
+		}
+	}
+	return volumes
+}
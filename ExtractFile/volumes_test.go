@@ -0,0 +1,57 @@
+package extractfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestArchiveVolumes(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string // файлы в папке, первый — архив, который передаётся в archiveVolumes
+		want  []string
+	}{
+		{"part rar", []string{"mod.part1.rar", "mod.part2.rar", "MOD.PART3.RAR", "other.part2.rar"},
+			[]string{"MOD.PART3.RAR", "mod.part1.rar", "mod.part2.rar"}},
+		{"part rar with spaces", []string{"mod (1).part1.rar", "mod (1).part2.rar", "mod.part2.rar"},
+			[]string{"mod (1).part1.rar", "mod (1).part2.rar"}},
+		{"old rar", []string{"mod.rar", "mod.r00", "mod.r01", "mod.r0", "mod2.r00", "mod.rar.bak"},
+			[]string{"mod.r00", "mod.r01", "mod.rar"}},
+		{"7z", []string{"mod.7z.001", "mod.7z.002", "mod.7z.0003", "mod.zip.002"},
+			[]string{"mod.7z.001", "mod.7z.002"}},
+		{"regex characters in name", []string{"mod+[v2].7z.001", "mod+[v2].7z.002", "modd[v2].7z.002"},
+			[]string{"mod+[v2].7z.001", "mod+[v2].7z.002"}},
+		{"single rar", []string{"mod.rar"}, []string{"mod.rar"}},
+		{"not an archive set", []string{"mod.zip", "mod.z01"}, []string{"mod.zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Папка с подходящим именем томом не считается
+			if err := os.Mkdir(filepath.Join(dir, "mod.part9.rar"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			volumes := archiveVolumes(filepath.Join(dir, tt.files[0]))
+			if volumes[0] != filepath.Join(dir, tt.files[0]) {
+				t.Errorf("first volume = %s, want the archive itself", volumes[0])
+			}
+			var got []string
+			for _, v := range volumes {
+				got = append(got, filepath.Base(v))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("archiveVolumes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package modpack
+
+import (
+	addons "DeadlockHelper/Addons"
+	extractfile "DeadlockHelper/ExtractFile"
+	gamebanana "DeadlockHelper/Parser"
+	store "DeadlockHelper/Store"
+	installlog "DeadlockHelper/installedmods"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// Plan — разница между набором и установленными модами
+type Plan struct {
+	Present    []Mod                     // уже установлены
+	Missing    []Mod                     // будут скачаны с GameBanana
+	Unresolved []Mod                     // локальные моды автора набора, скачать их нельзя
+	Extra      []installlog.InstalledMod // установлены, но в набор не входят, будут выключены
+}
+
+// PlanImport сравнивает набор с модами, установленными в папку Deadlock dir
+func PlanImport(m Manifest, dir string) (Plan, error) {
+	installed, err := installlog.LoadInstalledMods(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Plan{}, err
+	}
+
+	var plan Plan
+	inPack := make(map[int]bool)
+	for _, mod := range m.Mods {
+		switch {
+		case mod.IsLocal():
+			plan.Unresolved = append(plan.Unresolved, mod)
+		case isInstalled(installed, mod.ModID):
+			plan.Present = append(plan.Present, mod)
+			inPack[mod.ModID] = true
+		default:
+			plan.Missing = append(plan.Missing, mod)
+		}
+	}
+	for _, im := range installed {
+		if !inPack[im.ID] {
+			plan.Extra = append(plan.Extra, im)
+		}
+	}
+	return plan, nil
+}
+
+func isInstalled(mods []installlog.InstalledMod, id int) bool {
+	for _, m := range mods {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Failure — мод набора, который не удалось скачать или установить
+type Failure struct {
+	Mod Mod
+	Err error
+}
+
+// Result — итог импорта набора
+type Result struct {
+	Installed  []Mod
+	Unresolved []Mod
+	Failed     []Failure
+}
+
+// Import скачивает недостающие моды набора, затем включает и выключает моды и
+// раскладывает их в addons в порядке набора. Мод, который не удалось установить,
+// попадает в Result.Failed и не мешает остальным. progress вызывается перед
+// скачиванием каждого мода и может быть nil.
+func Import(m Manifest, dir string, progress func(done, total int, name string)) (Result, error) {
+	if err := addons.EnsureGameClosed(); err != nil {
+		return Result{}, err
+	}
+
+	plan, err := PlanImport(m, dir)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Unresolved: plan.Unresolved}
+	for i, mod := range plan.Missing {
+		if progress != nil {
+			progress(i, len(plan.Missing), mod.Name)
+		}
+		if err := installMod(mod, dir); err != nil {
+			result.Failed = append(result.Failed, Failure{Mod: mod, Err: err})
+			continue
+		}
+		result.Installed = append(result.Installed, mod)
+	}
+	if progress != nil {
+		progress(len(plan.Missing), len(plan.Missing), "")
+	}
+
+	// Устанавливать нечего и выключать нечего — журнала установленных модов может не быть
+	if len(result.Installed) == 0 && len(plan.Present) == 0 && len(plan.Extra) == 0 {
+		return result, nil
+	}
+
+	profile := installlog.Profile{Name: "modpack"}
+	for _, mod := range m.Mods {
+		if !mod.IsLocal() {
+			profile.Mods = append(profile.Mods, installlog.ProfileMod{ID: mod.ModID, Enabled: mod.Enabled})
+		}
+	}
+	if _, err := installlog.ApplyProfile(profile, dir); err != nil {
+		return result, fmt.Errorf("не удалось применить порядок набора: %w", err)
+	}
+	return result, nil
+}
+
+// installMod скачивает именно тот файл мода, что указан в наборе, и устанавливает его
+func installMod(mod Mod, dir string) error {
+	info, err := gamebanana.FetchMod(mod.ModID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить данные мода: %w", err)
+	}
+	if info.Name == "" {
+		info.Name = mod.Name
+	}
+
+	files, err := gamebanana.FetchModFiles(mod.ModID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить данные файла: %w", err)
+	}
+	file, err := gamebanana.PickFile(files, mod.ModID, mod.FileID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить данные файла: %w", err)
+	}
+	if mod.MD5 != "" && file.MD5 != "" && !strings.EqualFold(mod.MD5, file.MD5) {
+		return fmt.Errorf("файл мода на GameBanana изменился с момента создания набора")
+	}
+
+	record := installlog.InstalledMod{
+		ID:        mod.ModID,
+		Name:      info.Name,
+		ImageURL:  info.ImageURL(),
+		Installed: time.Now(),
+		Enabled:   true,
+		FileID:    file.ID,
+		MD5:       file.MD5,
+	}
+
+	// Тот же файл уже скачивался — ставим его из хранилища без загрузки
+	if hash, ok := store.Lookup(file.MD5); ok {
+		if record.Path, err = store.Install(hash, addons.Dir(dir)); err == nil {
+			record.SHA256 = hash
+			record.Heroes, _ = addons.Heroes(record.Path)
+			return saveRecord(record, dir)
+		}
+	}
+
+	download, err := gamebanana.DownloadFile(files, file, dir)
+	if err != nil {
+		return fmt.Errorf("не удалось скачать: %w", err)
+	}
+	installed, err := extractfile.Install(download.Path, dir, "")
+	if err != nil {
+		return fmt.Errorf("не удалось установить мод: %w", err)
+	}
+	_ = store.Remember(download.MD5, installed.SHA256)
+
+	record.Path = installed.Path
+	record.SHA256 = installed.SHA256
+	record.Heroes, _ = addons.Heroes(installed.Path)
+	return saveRecord(record, dir)
+}
+
+// saveRecord записывает мод в журнал. Если запись не удалась, VPK убирается из
+// addons: мод, которого нет в журнале, программа не сможет ни удалить, ни выключить.
+func saveRecord(record installlog.InstalledMod, dir string) error {
+	if err := installlog.SaveInstalledMod(record, dir); err != nil {
+		os.Remove(record.Path)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package modpack
+
+import (
+	installlog "DeadlockHelper/installedmods"
+	"reflect"
+	"testing"
+)
+
+func TestPlanImport(t *testing.T) {
+	dir := testGame(t,
+		installlog.InstalledMod{ID: 501234, Name: "Новый интерфейс", Path: "pak01_dir.vpk", Enabled: true},
+		installlog.InstalledMod{ID: 777, Name: "Чужой", Path: "pak02_dir.vpk", Enabled: true},
+	)
+
+	plan, err := PlanImport(testManifest, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(mods []Mod) []string {
+		var out []string
+		for _, m := range mods {
+			out = append(out, m.Name)
+		}
+		return out
+	}
+	if got := names(plan.Present); !reflect.DeepEqual(got, []string{"Новый интерфейс"}) {
+		t.Errorf("Present = %v", got)
+	}
+	if got := names(plan.Missing); !reflect.DeepEqual(got, []string{"Haze skin"}) {
+		t.Errorf("Missing = %v", got)
+	}
+	if got := names(plan.Unresolved); !reflect.DeepEqual(got, []string{"Мой локальный мод"}) {
+		t.Errorf("Unresolved = %v", got)
+	}
+	if len(plan.Extra) != 1 || plan.Extra[0].ID != 777 {
+		t.Errorf("Extra = %+v", plan.Extra)
+	}
+
+	// Без журнала все моды GameBanana недостающие
+	plan, err = PlanImport(testManifest, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Missing) != 2 || len(plan.Present) != 0 || len(plan.Extra) != 0 {
+		t.Errorf("plan without a log = %+v", plan)
+	}
+}
@@ -0,0 +1,157 @@
+package modpack
+
+import (
+	installlog "DeadlockHelper/installedmods"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ManifestVersion — текущая версия формата набора модов. Наборы более новой версии
+// не импортируются: их поля могут значить то, чего эта версия программы не понимает.
+const ManifestVersion = 1
+
+// codePrefix начинает короткий код набора; цифра совпадает с версией формата кода
+const codePrefix = "DLH1-"
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported modpack version")
+	ErrTooLarge           = errors.New("modpack is too large")
+)
+
+// Manifest — набор модов, которым можно поделиться: моды GameBanana в порядке загрузки
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Mods    []Mod     `json:"mods"` // первым идёт мод с наибольшим приоритетом
+}
+
+// Mod — мод в наборе
+type Mod struct {
+	ModID   int    `json:"mod_id"`            // ID мода на GameBanana, 0 или меньше — локальный мод
+	FileID  int    `json:"file_id,omitempty"` // ID файла мода на GameBanana
+	MD5     string `json:"md5,omitempty"`     // MD5 файла по данным GameBanana
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// IsLocal сообщает, что мод не с GameBanana и у получателя набора его не скачать
+func (m Mod) IsLocal() bool {
+	return m.ModID <= 0
+}
+
+// Export собирает набор из модов, установленных в папку Deadlock dir. Составной мод
+// собран локально, и скачать его нельзя, поэтому вместо него в набор попадают
+// исходные моды в его порядке: у получателя они установятся по отдельности.
+func Export(dir string) (Manifest, error) {
+	mods, err := installlog.LoadInstalledMods(dir)
+	if err != nil {
+		return Manifest{}, err
+	}
+	installlog.SortByLoadOrder(mods)
+
+	m := Manifest{Version: ManifestVersion, Created: time.Now().UTC()}
+	for _, im := range mods {
+		if !im.IsComposite() {
+			m.Mods = append(m.Mods, exportMod(im, im.Enabled))
+			continue
+		}
+		for _, src := range im.Sources {
+			m.Mods = append(m.Mods, exportMod(src, im.Enabled))
+		}
+	}
+	return m, nil
+}
+
+func exportMod(im installlog.InstalledMod, enabled bool) Mod {
+	return Mod{
+		ModID:   im.ID,
+		FileID:  im.FileID,
+		MD5:     im.MD5,
+		Name:    im.Name,
+		Enabled: enabled,
+	}
+}
+
+// Encode возвращает набор в виде JSON для сохранения в файл
+func (m Manifest) Encode() ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
+
+// Code возвращает набор в виде короткой строки, которую удобно вставить в чат
+func (m Manifest) Code() (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return codePrefix + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// Parse читает набор из JSON-файла или из короткого кода
+func Parse(data []byte) (Manifest, error) {
+	if len(data) > maxManifestSize {
+		return Manifest{}, ErrTooLarge
+	}
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, codePrefix) {
+		decoded, err := decodeCode(strings.TrimPrefix(text, codePrefix))
+		if err != nil {
+			return Manifest{}, fmt.Errorf("invalid modpack code: %w", err)
+		}
+		text = string(decoded)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal([]byte(text), &m); err != nil {
+		return Manifest{}, fmt.Errorf("invalid modpack: %w", err)
+	}
+	if m.Version < 1 || m.Version > ManifestVersion {
+		return Manifest{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, m.Version)
+	}
+	return m, nil
+}
+
+// decodeCode распаковывает тело короткого кода. Пробелы и переносы строк, которые
+// добавляют мессенджеры, пропускаются.
+func decodeCode(body string) ([]byte, error) {
+	body = strings.Join(strings.Fields(body), "")
+	compressed, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestSize {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// maxManifestSize ограничивает набор и распакованный код, чтобы короткая строка не
+// развернулась в гигабайты
+const maxManifestSize = 1 << 20
@@ -0,0 +1,147 @@
+package modpack
+
+import (
+	installlog "DeadlockHelper/installedmods"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testManifest = Manifest{
+	Version: ManifestVersion,
+	Created: time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC),
+	Mods: []Mod{
+		{ModID: 501234, FileID: 1300001, MD5: "0123456789abcdef0123456789abcdef", Name: "Новый интерфейс", Enabled: true},
+		{ModID: 502000, FileID: 1300500, Name: "Haze skin", Enabled: false},
+		{ModID: -1, Name: "Мой локальный мод", Enabled: true},
+	},
+}
+
+// gzipCode собирает короткий код из произвольного тела
+func gzipCode(t *testing.T, body []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return codePrefix + base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	code, err := testManifest.Code()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(code, codePrefix) {
+		t.Fatalf("code %q has no prefix", code)
+	}
+	encoded, err := testManifest.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Мессенджеры переносят длинный код и добавляют пробелы по краям
+	wrapped := "  " + code[:20] + "\n" + code[20:40] + " \r\n" + code[40:] + "\n"
+	for name, input := range map[string]string{"code": code, "wrapped code": wrapped, "json": string(encoded)} {
+		got, err := Parse([]byte(input))
+		if err != nil {
+			t.Errorf("Parse(%s): %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, testManifest) {
+			t.Errorf("Parse(%s) = %+v, want %+v", name, got, testManifest)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	huge := strings.Repeat("a", maxManifestSize)
+	tests := []struct {
+		name  string
+		input string
+		want  error // nil — любая ошибка
+	}{
+		{"empty", "", nil},
+		{"not json", "modpack", nil},
+		{"truncated json", `{"version": 1, "mods": [`, nil},
+		{"bad base64", codePrefix + "!!!", nil},
+		{"not gzip", codePrefix + base64.RawURLEncoding.EncodeToString([]byte("plain text")), nil},
+		{"code without json", gzipCode(t, []byte("not json")), nil},
+		{"truncated code", gzipCode(t, []byte(`{"version":1}`))[:20], nil},
+		{"version 0", `{"version": 0, "mods": []}`, ErrUnsupportedVersion},
+		{"newer version", `{"version": 2, "mods": []}`, ErrUnsupportedVersion},
+		{"newer version code", gzipCode(t, []byte(`{"version": 2}`)), ErrUnsupportedVersion},
+		{"oversized json", `{"version": 1, "mods": [{"name": "` + huge + `"}]}`, ErrTooLarge},
+		// Несколько килобайт кода распаковываются больше чем в мегабайт
+		{"oversized code", gzipCode(t, []byte(`{"version": 1, "mods": [{"name": "`+huge+`"}]}`)), ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse([]byte(tt.input))
+			if err == nil {
+				t.Fatalf("Parse accepted %q as %+v", tt.input[:min(len(tt.input), 40)], m)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Parse error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// testGame переносит папку настроек во временную папку и возвращает папку игры
+// с журналом из mods
+func testGame(t *testing.T, mods ...installlog.InstalledMod) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir := t.TempDir()
+	for _, m := range mods {
+		if err := installlog.SaveInstalledMod(m, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExportComposite(t *testing.T) {
+	dir := testGame(t,
+		installlog.InstalledMod{ID: 100, Name: "Отдельный", Path: "pak03_dir.vpk", Enabled: true, FileID: 10, MD5: "aa"},
+		installlog.InstalledMod{
+			ID: -2, Name: "Сборка", Path: "pak01_dir.vpk", Enabled: true, SHA256: "ff",
+			Sources: []installlog.InstalledMod{
+				{ID: 200, Name: "Первый", FileID: 20, MD5: "bb", SHA256: "b2"},
+				{ID: -1, Name: "Свой", SHA256: "c3"},
+				{ID: 300, Name: "Второй", FileID: 30, MD5: "dd", SHA256: "d4"},
+			},
+		},
+		installlog.InstalledMod{ID: 400, Name: "Выключенный", Path: "pak02_dir.vpk.disabled", Slot: 2, FileID: 40},
+	)
+
+	m, err := Export(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Mod{
+		{ModID: 200, FileID: 20, MD5: "bb", Name: "Первый", Enabled: true},
+		{ModID: -1, Name: "Свой", Enabled: true},
+		{ModID: 300, FileID: 30, MD5: "dd", Name: "Второй", Enabled: true},
+		{ModID: 400, FileID: 40, Name: "Выключенный"},
+		{ModID: 100, FileID: 10, MD5: "aa", Name: "Отдельный", Enabled: true},
+	}
+	if !reflect.DeepEqual(m.Mods, want) {
+		t.Errorf("Export mods = %+v\nwant %+v", m.Mods, want)
+	}
+	if m.Version != ManifestVersion {
+		t.Errorf("version = %d, want %d", m.Version, ManifestVersion)
+	}
+}
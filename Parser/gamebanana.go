@@ -1,15 +1,19 @@
 package gamebanana
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 )
 
 // --- структура для списка модов
@@ -42,9 +46,12 @@ func (m Mod) ImageURL() string {
 	return ""
 }
 
+// GameID — ID Deadlock на GameBanana
+const GameID = 20948
+
 // FetchMods возвращает первые 20 модов для игры с ID=20948
 func FetchMods(page int) ([]Mod, error) {
-	urlMods := fmt.Sprintf("https://gamebanana.com/apiv11/Mod/Index?_nPerpage=20&_nPage=%d&_aFilters[Generic_Game]=20948", page)
+	urlMods := fmt.Sprintf("https://gamebanana.com/apiv11/Mod/Index?_nPerpage=20&_nPage=%d&_aFilters[Generic_Game]=%d", page, GameID)
 	resp, err := http.Get(urlMods)
 	if err != nil {
 		return nil, err
@@ -58,92 +65,234 @@ func FetchMods(page int) ([]Mod, error) {
 	return data.ARecords, nil
 }
 
-// --- структура для получения ссылки на файл
-type ModFilesResponse struct {
-	ARecords []struct {
-		DownloadURL string `json:"_sDownloadUrl"`
-		FileName    string `json:"_sFile"` // e.g. "pak25_dir.vpk"
-	} `json:"_aFiles"`
-}
-
-// DownloadModToDir скачивает VPK файл мода и сохраняет его в папку dir.
-// Если внутри директории уже есть файлы вида prefixNN[_suffix].vpk, то новый будет назван с номером на 1 больше.
-func DownloadModToDir(modID int, dir string) (string, error) {
-	// Запрос к API за данными файла
-	apiURL := fmt.Sprintf("https://gamebanana.com/apiv11/Mod/%d?_csvProperties=_aFiles", modID)
-	resp, err := http.Get(apiURL)
+// SearchMods ищет моды игры gameID по строке query
+func SearchMods(query string, gameID int) ([]Mod, error) {
+	api := fmt.Sprintf(
+		"https://gamebanana.com/apiv11/Util/Search/Results?_sSearchString=%s&_idGameRow=%d",
+		url.QueryEscape(query), gameID)
+	resp, err := http.Get(api)
 	if err != nil {
-		return "", fmt.Errorf("API request error: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected API status: %s", resp.Status)
+		return nil, fmt.Errorf("search request failed: %s", resp.Status)
+	}
+	var out ApiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
 	}
+	return out.ARecords, nil
+}
 
-	var data ModFilesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", fmt.Errorf("JSON decode error: %w", err)
+// --- структура для получения ссылки на файл
+type ModFilesResponse struct {
+	ARecords []ModFile `json:"_aFiles"`
+}
+
+type ModFile struct {
+	ID          int    `json:"_idRow"`
+	DownloadURL string `json:"_sDownloadUrl"`
+	FileName    string `json:"_sFile"` // e.g. "pak25_dir.vpk"
+	MD5         string `json:"_sMd5Checksum"`
+}
+
+// Схемы имён многотомных архивов. Группа 1 — общий префикс набора, группа 2 — номер тома.
+var volumePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^(.+)\.part(\d+)\.rar$`), // mod.part1.rar, mod.part2.rar
+	regexp.MustCompile(`(?i)^(.+\.7z)\.(\d{3})$`),    // mod.7z.001, mod.7z.002
+	regexp.MustCompile(`(?i)^(.+)\.r(ar|\d{2})$`),    // mod.rar, mod.r00, mod.r01
+}
+
+// volumeSet возвращает все тома многотомного архива, к которому относится файл name,
+// упорядоченные от первого тома, и длину общего префикса их имён без расширения.
+// Для обычного файла возвращается nil.
+func volumeSet(files []ModFile, name string) ([]ModFile, int) {
+	for _, re := range volumePatterns {
+		m := re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		var set []ModFile
+		for _, f := range files {
+			if fm := re.FindStringSubmatch(f.FileName); fm != nil && strings.EqualFold(fm[1], m[1]) {
+				set = append(set, f)
+			}
+		}
+		if len(set) < 2 {
+			return nil, 0
+		}
+		sort.Slice(set, func(i, j int) bool {
+			return volumeIndex(re, set[i].FileName) < volumeIndex(re, set[j].FileName)
+		})
+		// Номер от uniqueVolumePaths встаёт перед расширением префикса: mod (1).7z.001
+		return set, len(m[1]) - len(filepath.Ext(m[1]))
 	}
-	if len(data.ARecords) == 0 {
-		return "", errors.New("no files found for mod")
+	return nil, 0
+}
+
+// volumeIndex возвращает порядковый номер тома; у mod.rar в старой схеме он меньше, чем у mod.r00
+func volumeIndex(re *regexp.Regexp, name string) int {
+	num := re.FindStringSubmatch(name)[2]
+	if strings.EqualFold(num, "ar") {
+		return -1
 	}
+	var n int
+	fmt.Sscanf(num, "%d", &n)
+	return n
+}
 
-	fileInfo := data.ARecords[0]
+// Download — результат скачивания файла мода
+type Download struct {
+	Path   string // путь к скачанному файлу (первому тому, если архив многотомный)
+	FileID int    // ID файла на GameBanana
+	MD5    string // MD5 файла по данным GameBanana
+}
+
+// DownloadFile скачивает файл fileInfo из списка files, уже полученного от
+// FetchModFiles, в папку dir под оригинальным именем. Остальные файлы списка нужны,
+// чтобы найти тома многотомного архива. MD5 скачанного файла сверяется с данными
+// GameBanana.
+func DownloadFile(files []ModFile, fileInfo ModFile, dir string) (Download, error) {
 	downloadURL := fileInfo.DownloadURL
 	fileName := fileInfo.FileName // e.g. "pak25_dir.vpk"
 	if downloadURL == "" || fileName == "" {
-		return "", errors.New("incomplete file data")
+		return Download{}, errors.New("incomplete file data")
 	}
+	result := Download{FileID: fileInfo.ID, MD5: fileInfo.MD5}
 
 	// Создаём директорию, если нет
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
+		return Download{}, err
 	}
 
-	// Регулярка с учётом необязательного суффикса, до 99
-	// Группы: 1-prefix, 2-num, 3-suffix (например "_dir"), 4-ext
-	re := regexp.MustCompile(`^([a-zA-Z]+)(\d{1,2})(_[^\.]+)?(\.[^.]+)$`)
-	matches := re.FindStringSubmatch(fileName)
-	if len(matches) != 5 {
-		// Не удалось распарсить — сохраняем оригинал
-		return downloadAndSave(downloadURL, filepath.Join(dir, fileName))
+	// Многотомный архив скачиваем целиком под оригинальными именами,
+	// чтобы распаковщик нашёл следующие тома рядом с первым
+	if volumes, prefixLen := volumeSet(files, fileName); len(volumes) > 1 {
+		paths := uniqueVolumePaths(dir, volumes, prefixLen)
+		for i, v := range volumes {
+			if v.DownloadURL == "" || v.FileName == "" {
+				removeFiles(paths[:i])
+				return Download{}, errors.New("incomplete file data")
+			}
+			if _, err := downloadAndSave(v.DownloadURL, paths[i], v.MD5); err != nil {
+				removeFiles(paths[:i])
+				return Download{}, fmt.Errorf("failed to download volume %s: %w", v.FileName, err)
+			}
+		}
+		result.Path = paths[0]
+		return result, nil
 	}
-	prefix := matches[1]
-	suffix := matches[3] // может быть "" или "_dir"
-	ext := matches[4]
 
-	// Собираем существующие номера
-	nums := []int{}
-	files, err := os.ReadDir(dir)
+	// Номер pak назначается при установке в addons, здесь важно лишь не затереть
+	// другой скачанный, но ещё не установленный файл
+	path, err := downloadAndSave(downloadURL, uniquePath(dir, filepath.Base(fileName)), fileInfo.MD5)
 	if err != nil {
-		return "", err
+		return Download{}, err
+	}
+	result.Path = path
+	return result, nil
+}
+
+// FetchModFiles запрашивает у API список файлов мода. Список не бывает пустым.
+func FetchModFiles(modID int) ([]ModFile, error) {
+	apiURL := fmt.Sprintf("https://gamebanana.com/apiv11/Mod/%d?_csvProperties=_aFiles", modID)
+	resp, err := http.Get(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("API request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected API status: %s", resp.Status)
+	}
+
+	var data ModFilesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("JSON decode error: %w", err)
+	}
+	if len(data.ARecords) == 0 {
+		return nil, errors.New("no files found for mod")
+	}
+	return data.ARecords, nil
+}
+
+// PickFile выбирает файл fileID среди файлов мода, а при fileID == 0 — первый.
+// По MD5 выбранного файла можно понять, что он уже скачивался.
+func PickFile(files []ModFile, modID, fileID int) (ModFile, error) {
+	if fileID == 0 {
+		return files[0], nil
 	}
 	for _, f := range files {
-		sub := re.FindStringSubmatch(f.Name())
-		if len(sub) == 5 && sub[1] == prefix && sub[3] == suffix {
-			var n int
-			fmt.Sscanf(sub[2], "%d", &n)
-			nums = append(nums, n)
+		if f.ID == fileID {
+			return f, nil
 		}
 	}
-	// Находим следующий номер
-	sort.Ints(nums)
-	next := 1
-	if len(nums) > 0 {
-		next = nums[len(nums)-1] + 1
+	return ModFile{}, fmt.Errorf("file %d not found for mod %d", fileID, modID)
+}
+
+// FetchMod возвращает название и превью мода по его ID
+func FetchMod(modID int) (Mod, error) {
+	apiURL := fmt.Sprintf("https://gamebanana.com/apiv11/Mod/%d?_csvProperties=_idRow,_sName,_aPreviewMedia", modID)
+	resp, err := http.Get(apiURL)
+	if err != nil {
+		return Mod{}, fmt.Errorf("API request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Mod{}, fmt.Errorf("unexpected API status: %s", resp.Status)
+	}
+
+	var mod Mod
+	if err := json.NewDecoder(resp.Body).Decode(&mod); err != nil {
+		return Mod{}, fmt.Errorf("JSON decode error: %w", err)
 	}
-	if next > 99 {
-		return "", fmt.Errorf("too many files for prefix %s%s", prefix, suffix)
+	return mod, nil
+}
+
+// uniquePath возвращает путь к файлу name в dir, добавляя к имени номер, если такой файл уже есть
+func uniquePath(dir, name string) string {
+	ext := filepath.Ext(name)
+	return uniqueVolumePaths(dir, []ModFile{{FileName: name}}, len(name)-len(ext))[0]
+}
+
+// uniqueVolumePaths возвращает пути к томам volumes в dir. Если хоть один том затёр
+// бы существующий файл, номер добавляется к общему префиксу длиной prefixLen во всех
+// именах сразу (mod (1).part1.rar, mod (1).part2.rar), чтобы тома остались набором.
+func uniqueVolumePaths(dir string, volumes []ModFile, prefixLen int) []string {
+	paths := make([]string, len(volumes))
+	for i := 0; ; i++ {
+		free := true
+		for j, v := range volumes {
+			name := filepath.Base(v.FileName)
+			if i > 0 {
+				// Префикс считается по имени из API, в котором может быть и папка
+				cut := max(prefixLen-(len(v.FileName)-len(name)), 0)
+				name = fmt.Sprintf("%s (%d)%s", name[:cut], i, name[cut:])
+			}
+			paths[j] = filepath.Join(dir, name)
+			if _, err := os.Stat(paths[j]); !os.IsNotExist(err) {
+				free = false
+			}
+		}
+		if free {
+			return paths
+		}
 	}
-	newName := fmt.Sprintf("%s%02d%s%s", prefix, next, suffix, ext)
-	outPath := filepath.Join(dir, newName)
+}
 
-	return downloadAndSave(downloadURL, outPath)
+// removeFiles удаляет уже скачанные тома, если набор скачать не удалось
+func removeFiles(paths []string) {
+	for _, p := range paths {
+		os.Remove(p)
+	}
 }
 
-// downloadAndSave скачивает по URL и сохраняет в указанный путь
-func downloadAndSave(url, outPath string) (string, error) {
+// downloadAndSave скачивает по URL и сохраняет в указанный путь. Если известен
+// ожидаемый MD5, он сверяется с содержимым, и при расхождении файл удаляется.
+func downloadAndSave(url, outPath, expectedMD5 string) (string, error) {
 	downloadResp, err := http.Get(url)
 	if err != nil {
 		return "", err
@@ -160,9 +309,19 @@ func downloadAndSave(url, outPath string) (string, error) {
 	}
 	defer f.Close()
 
-	if _, err := io.Copy(f, downloadResp.Body); err != nil {
+	hash := md5.New()
+	if _, err := io.Copy(io.MultiWriter(f, hash), downloadResp.Body); err != nil {
+		// Недокачанный файл не должен остаться в папке: его приняли бы за целый архив
+		f.Close()
+		os.Remove(outPath)
 		return "", err
 	}
 
+	if sum := hex.EncodeToString(hash.Sum(nil)); expectedMD5 != "" && !strings.EqualFold(sum, expectedMD5) {
+		f.Close()
+		os.Remove(outPath)
+		return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", filepath.Base(outPath), sum, expectedMD5)
+	}
+
 	return outPath, nil
 }
@@ -0,0 +1,63 @@
+package gamebanana
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// matchCandidates — сколько результатов поиска проверяется по списку файлов
+const matchCandidates = 5
+
+// Match — мод GameBanana, которому, судя по всему, принадлежит VPK
+type Match struct {
+	Mod   Mod
+	File  ModFile
+	ByMD5 bool // совпал MD5 файла, а не только имя
+}
+
+// slotNameRe — имена, которые выдаёт установщик. По ним мод не найти.
+var slotNameRe = regexp.MustCompile(`(?i)^pak\d{2}$`)
+
+// MatchFile ищет на GameBanana мод, к которому относится файл name с MD5 md5.
+// Поиск идёт по имени файла, среди первых результатов предпочитается мод с файлом
+// того же MD5, затем — с файлом того же имени. Если ничего не подошло, ok равен false.
+func MatchFile(name, md5 string) (match Match, ok bool, err error) {
+	stem := fileStem(name)
+	if stem == "" || slotNameRe.MatchString(stem) {
+		return Match{}, false, nil
+	}
+	query := strings.Join(strings.FieldsFunc(stem, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	}), " ")
+
+	mods, err := SearchMods(query, GameID)
+	if err != nil {
+		return Match{}, false, err
+	}
+	if len(mods) > matchCandidates {
+		mods = mods[:matchCandidates]
+	}
+	for _, mod := range mods {
+		files, err := FetchModFiles(mod.ID)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			switch {
+			case md5 != "" && strings.EqualFold(f.MD5, md5):
+				return Match{Mod: mod, File: f, ByMD5: true}, true, nil
+			case !ok && strings.EqualFold(fileStem(f.FileName), stem):
+				match, ok = Match{Mod: mod, File: f}, true
+			}
+		}
+	}
+	return match, ok, nil
+}
+
+// fileStem возвращает имя файла без расширения и суффикса _dir: hero_skin_dir.vpk -> hero_skin
+func fileStem(name string) string {
+	name = filepath.Base(name)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return strings.TrimSuffix(strings.TrimSuffix(name, "_dir"), "_DIR")
+}
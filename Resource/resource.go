@@ -0,0 +1,104 @@
+package resource
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Скомпилированный ресурс Source 2 (*.vtex_c, *.vsnd_c, ...):
+//
+//	заголовок   размер файла, версия заголовка (12), версия ресурса,
+//	            смещение и число блоков
+//	блоки       четыре буквы типа, смещение от поля смещения, размер
+//	данные      содержимое блоков (RERL, REDI, DATA, ...)
+//
+// Иногда за последним блоком лежат «сырые» данные ресурса, например пиксели
+// текстуры, — их смещение и размер знает только сам формат.
+
+const (
+	headerSize    = 16
+	blockSize     = 12
+	headerVersion = 12
+)
+
+var (
+	ErrNotResource = errors.New("not a source 2 resource")
+	ErrCorrupt     = errors.New("corrupt resource")
+	ErrNoBlock     = errors.New("resource block not found")
+)
+
+// Block — блок ресурса
+type Block struct {
+	Type   string // DATA, RERL, REDI, ...
+	Offset uint32 // смещение от начала файла
+	Size   uint32
+}
+
+// Resource — разобранный заголовок ресурса вместе с содержимым файла
+type Resource struct {
+	FileSize uint32
+	Version  uint16
+	Blocks   []Block
+
+	data []byte
+}
+
+// Parse разбирает заголовок и таблицу блоков ресурса
+func Parse(data []byte) (*Resource, error) {
+	if len(data) < headerSize {
+		return nil, ErrNotResource
+	}
+	le := binary.LittleEndian
+	if le.Uint16(data[4:]) != headerVersion {
+		return nil, ErrNotResource
+	}
+	r := &Resource{
+		FileSize: le.Uint32(data[0:]),
+		Version:  le.Uint16(data[6:]),
+		data:     data,
+	}
+
+	tableOffset := 8 + uint64(le.Uint32(data[8:]))
+	count := uint64(le.Uint32(data[12:]))
+	if tableOffset+count*blockSize > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: block table is outside the file", ErrCorrupt)
+	}
+	for i := uint64(0); i < count; i++ {
+		at := tableOffset + i*blockSize
+		b := Block{
+			Type:   string(data[at : at+4]),
+			Offset: uint32(at + 4 + uint64(le.Uint32(data[at+4:]))),
+			Size:   le.Uint32(data[at+8:]),
+		}
+		if uint64(b.Offset)+uint64(b.Size) > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: block %s is outside the file", ErrCorrupt, b.Type)
+		}
+		r.Blocks = append(r.Blocks, b)
+	}
+	return r, nil
+}
+
+// Block возвращает первый блок указанного типа
+func (r *Resource) Block(typ string) (Block, bool) {
+	for _, b := range r.Blocks {
+		if b.Type == typ {
+			return b, true
+		}
+	}
+	return Block{}, false
+}
+
+// Data возвращает содержимое блока typ
+func (r *Resource) Data(typ string) ([]byte, error) {
+	b, ok := r.Block(typ)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoBlock, typ)
+	}
+	return r.data[b.Offset : b.Offset+b.Size], nil
+}
+
+// Bytes возвращает содержимое всего файла
+func (r *Resource) Bytes() []byte {
+	return r.data
+}
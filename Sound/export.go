@@ -0,0 +1,61 @@
+package sound
+
+import (
+	vpk "DeadlockHelper/VPK"
+	"DeadlockHelper/internal/fsutil"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Export извлекает все звуки VPK в папку outDir, сохраняя пути внутри VPK:
+// sounds/ui/click.vsnd_c → outDir/sounds/ui/click.wav. Возвращает пути
+// записанных файлов. Звуки в неизвестных форматах пропускаются.
+func Export(vpkPath, outDir string) ([]string, error) {
+	archive, err := vpk.Open(vpkPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, p := range archive.Paths() {
+		if !strings.HasSuffix(p, ".vsnd_c") {
+			continue
+		}
+		data, err := archive.ReadFile(p)
+		if err != nil {
+			return written, err
+		}
+		audio, err := Decode(data)
+		if err != nil {
+			fmt.Println("Failed to decode sound:", p, err)
+			continue
+		}
+		outPath, err := fsutil.SafeJoin(outDir, strings.TrimSuffix(p, ".vsnd_c")+audio.Format.Ext())
+		if err != nil {
+			return written, fmt.Errorf("%w: %w", vpk.ErrCorrupt, err)
+		}
+		if err := writeAudio(audio, outPath); err != nil {
+			return written, fmt.Errorf("%s: %w", p, err)
+		}
+		written = append(written, outPath)
+	}
+	return written, nil
+}
+
+func writeAudio(audio Audio, outPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if _, err := audio.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(outPath)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,51 @@
+package sound
+
+import (
+	vpk "DeadlockHelper/VPK"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	files := map[string][]byte{
+		"sounds/ui/click.vsnd_c":    buildVSnd(4, v4Info(44100, v4PCM16, 1, 0), pcmData),
+		"sounds/music/theme.vsnd_c": buildVSnd(4, v4Info(44100, v4MP3, 2, 0), mp3Data),
+		"sounds/broken.vsnd_c":      []byte("not a resource"),
+		"materials/icon.vtex_c":     []byte("texture"),
+	}
+	var sources []vpk.Source
+	for p, data := range files {
+		sources = append(sources, vpk.Source{Path: p, Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}})
+	}
+	vpkPath := filepath.Join(t.TempDir(), "pak01_dir.vpk")
+	if err := vpk.Create(vpkPath, sources); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	written, err := Export(vpkPath, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(out, "sounds", "music", "theme.mp3"),
+		filepath.Join(out, "sounds", "ui", "click.wav"),
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Fatalf("Export = %v, want %v", written, want)
+	}
+	mp3, err := os.ReadFile(want[0])
+	if err != nil || !bytes.Equal(mp3, mp3Data) {
+		t.Errorf("theme.mp3 = %q, %v", mp3, err)
+	}
+	wav, err := os.ReadFile(want[1])
+	if err != nil || len(wav) != 44+len(pcmData) || string(wav[:4]) != "RIFF" {
+		t.Errorf("click.wav = %d bytes, %v", len(wav), err)
+	}
+}
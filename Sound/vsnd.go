@@ -0,0 +1,227 @@
+package sound
+
+import (
+	resource "DeadlockHelper/Resource"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Блок DATA скомпилированного звука (*.vsnd_c) в версиях ресурса 0–3 начинается
+// с упакованного в 32 бита описания (тип, разрядность, каналы, размер сэмпла,
+// формат, частота), в версии 4 — с частоты (uint16), формата и числа каналов.
+// Дальше у всех версий: начало петли, число сэмплов, длительность, 12 байт
+// ссылок и размер звуковых данных. Сами данные лежат сразу после блока DATA:
+// MP3-файл целиком или PCM без заголовка WAV.
+//
+// Новые ресурсы хранят DATA в KeyValues3. Их описание не разбирается, но MP3 и
+// готовый WAV узнаются по сигнатуре данных.
+
+// Format — формат звуковых данных
+type Format int
+
+const (
+	FormatWAV Format = iota
+	FormatMP3
+	FormatAAC
+)
+
+// Ext возвращает расширение файла для формата
+func (f Format) Ext() string {
+	switch f {
+	case FormatMP3:
+		return ".mp3"
+	case FormatAAC:
+		return ".aac"
+	}
+	return ".wav"
+}
+
+var ErrUnsupportedFormat = errors.New("unsupported sound format")
+
+const (
+	// Типы файла в описании версий 0–3
+	typeAAC = 0
+	typeWAV = 1
+	typeMP3 = 2
+
+	// Форматы версии 4
+	v4PCM16 = 0
+	v4PCM8  = 1
+	v4MP3   = 2
+	v4ADPCM = 3
+
+	pcmFormat = 1 // WAVE_FORMAT_PCM
+)
+
+// kv3Magics — сигнатуры блока DATA в формате KeyValues3
+var kv3Magics = [][]byte{[]byte("\x03VK3"), []byte("VKV\x03"), []byte("KV3")}
+
+// Audio — звук, извлечённый из ресурса
+type Audio struct {
+	Format     Format
+	SampleRate int
+	Channels   int
+	Bits       int    // разрядность PCM, только для FormatWAV без заголовка
+	Data       []byte // MP3/AAC целиком, PCM без заголовка или готовый WAV
+	hasHeader  bool   // Data уже начинается с заголовка RIFF
+}
+
+// Decode извлекает звук из ресурса vsnd_c
+func Decode(data []byte) (Audio, error) {
+	res, err := resource.Parse(data)
+	if err != nil {
+		return Audio{}, err
+	}
+	block, ok := res.Block("DATA")
+	if !ok {
+		return Audio{}, fmt.Errorf("%w: DATA", resource.ErrNoBlock)
+	}
+	info := data[block.Offset : block.Offset+block.Size]
+	payload := data[block.Offset+block.Size:]
+
+	for _, magic := range kv3Magics {
+		if bytes.HasPrefix(info, magic) {
+			return sniff(payload)
+		}
+	}
+	return decodeBinary(res.Version, info, payload)
+}
+
+// decodeBinary разбирает двоичное описание звука версий 0–4
+func decodeBinary(version uint16, info, payload []byte) (Audio, error) {
+	if version > 4 {
+		return Audio{}, fmt.Errorf("%w: vsnd version %d", ErrUnsupportedFormat, version)
+	}
+	r := bytes.NewReader(info)
+	le := binary.LittleEndian
+	var a Audio
+	pcm := true
+
+	if version == 4 {
+		var head struct {
+			SampleRate uint16
+			Format     uint8
+			Channels   uint8
+		}
+		if err := binary.Read(r, le, &head); err != nil {
+			return Audio{}, fmt.Errorf("%w: %v", resource.ErrCorrupt, err)
+		}
+		a.SampleRate, a.Channels = int(head.SampleRate), int(head.Channels)
+		switch head.Format {
+		case v4PCM16:
+			a.Format, a.Bits = FormatWAV, 16
+		case v4PCM8:
+			a.Format, a.Bits = FormatWAV, 8
+		case v4MP3:
+			a.Format = FormatMP3
+		case v4ADPCM:
+			return Audio{}, fmt.Errorf("%w: ADPCM", ErrUnsupportedFormat)
+		default:
+			return Audio{}, fmt.Errorf("%w: format %d", ErrUnsupportedFormat, head.Format)
+		}
+	} else {
+		var packed uint32
+		if err := binary.Read(r, le, &packed); err != nil {
+			return Audio{}, fmt.Errorf("%w: %v", resource.ErrCorrupt, err)
+		}
+		bits := func(offset, n uint) int { return int(packed >> offset & (1<<n - 1)) }
+		switch bits(0, 2) {
+		case typeAAC:
+			a.Format = FormatAAC
+		case typeWAV:
+			a.Format = FormatWAV
+		case typeMP3:
+			a.Format = FormatMP3
+		default:
+			return Audio{}, fmt.Errorf("%w: type %d", ErrUnsupportedFormat, bits(0, 2))
+		}
+		a.Bits = bits(2, 5)
+		a.Channels = bits(7, 2)
+		pcm = bits(12, 2) == pcmFormat
+		a.SampleRate = bits(14, 17)
+	}
+
+	var tail struct {
+		LoopStart     int32
+		SampleCount   uint32
+		Duration      float32
+		_             [12]byte // фразы субтитров и данные заголовка
+		StreamingSize uint32
+	}
+	if err := binary.Read(r, le, &tail); err != nil {
+		return Audio{}, fmt.Errorf("%w: %v", resource.ErrCorrupt, err)
+	}
+	if a.Format == FormatWAV && !pcm {
+		return Audio{}, fmt.Errorf("%w: ADPCM", ErrUnsupportedFormat)
+	}
+
+	a.Data = payload
+	if tail.StreamingSize > 0 {
+		if int(tail.StreamingSize) > len(payload) {
+			return Audio{}, fmt.Errorf("%w: sound data is truncated", resource.ErrCorrupt)
+		}
+		a.Data = payload[:tail.StreamingSize]
+	}
+	if len(a.Data) == 0 {
+		return Audio{}, fmt.Errorf("%w: no sound data", resource.ErrCorrupt)
+	}
+	if a.Format == FormatWAV {
+		if a.Channels == 0 || a.SampleRate == 0 || (a.Bits != 8 && a.Bits != 16) {
+			return Audio{}, fmt.Errorf("%w: pcm %d bit, %d channels, %d Hz", ErrUnsupportedFormat, a.Bits, a.Channels, a.SampleRate)
+		}
+		a.hasHeader = bytes.HasPrefix(a.Data, []byte("RIFF"))
+	}
+	return a, nil
+}
+
+// sniff узнаёт формат звуковых данных по их началу
+func sniff(payload []byte) (Audio, error) {
+	switch {
+	case bytes.HasPrefix(payload, []byte("RIFF")):
+		return Audio{Format: FormatWAV, Data: payload, hasHeader: true}, nil
+	case bytes.HasPrefix(payload, []byte("ID3")),
+		len(payload) > 1 && payload[0] == 0xFF && payload[1]&0xE0 == 0xE0:
+		return Audio{Format: FormatMP3, Data: payload}, nil
+	}
+	return Audio{}, fmt.Errorf("%w: unknown sound data", ErrUnsupportedFormat)
+}
+
+// WriteTo пишет звук в виде, который откроет обычный плеер: MP3 и AAC как есть,
+// PCM — с заголовком WAV
+func (a Audio) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	if a.Format == FormatWAV && !a.hasHeader {
+		header := wavHeader(a.SampleRate, a.Channels, a.Bits, len(a.Data))
+		n, err := w.Write(header)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	n, err := w.Write(a.Data)
+	return written + int64(n), err
+}
+
+// wavHeader собирает заголовок RIFF/WAVE для PCM
+func wavHeader(sampleRate, channels, bits, dataSize int) []byte {
+	le := binary.LittleEndian
+	blockAlign := channels * bits / 8
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	le.PutUint32(h[4:], uint32(36+dataSize))
+	copy(h[8:], "WAVE")
+	copy(h[12:], "fmt ")
+	le.PutUint32(h[16:], 16)
+	le.PutUint16(h[20:], pcmFormat)
+	le.PutUint16(h[22:], uint16(channels))
+	le.PutUint32(h[24:], uint32(sampleRate))
+	le.PutUint32(h[28:], uint32(sampleRate*blockAlign))
+	le.PutUint16(h[32:], uint16(blockAlign))
+	le.PutUint16(h[34:], uint16(bits))
+	copy(h[36:], "data")
+	le.PutUint32(h[40:], uint32(dataSize))
+	return h
+}
@@ -0,0 +1,208 @@
+package sound
+
+import (
+	resource "DeadlockHelper/Resource"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// buildVSnd собирает ресурс версии version с одним блоком DATA (описание info)
+// и звуковыми данными payload сразу после него
+func buildVSnd(version uint16, info, payload []byte) []byte {
+	le := binary.LittleEndian
+	const headerSize, tableSize = 16, 12
+	data := make([]byte, headerSize+tableSize)
+	le.PutUint16(data[4:], 12)
+	le.PutUint16(data[6:], version)
+	le.PutUint32(data[8:], headerSize-8) // таблица блоков сразу после заголовка
+	le.PutUint32(data[12:], 1)
+	copy(data[16:], "DATA")
+	le.PutUint32(data[20:], headerSize+tableSize-20)
+	le.PutUint32(data[24:], uint32(len(info)))
+	data = append(append(data, info...), payload...)
+	le.PutUint32(data[0:], uint32(len(data)))
+	return data
+}
+
+// packedInfo собирает описание версий 0–3: 32 бита полей и общий хвост
+func packedInfo(fileType, bits, channels, format, sampleRate int, streamingSize uint32) []byte {
+	packed := uint32(fileType) | uint32(bits)<<2 | uint32(channels)<<7 | uint32(format)<<12 | uint32(sampleRate)<<14
+	return appendTail(binary.LittleEndian.AppendUint32(nil, packed), streamingSize)
+}
+
+// v4Info собирает описание версии 4
+func v4Info(sampleRate uint16, format, channels uint8, streamingSize uint32) []byte {
+	info := binary.LittleEndian.AppendUint16(nil, sampleRate)
+	return appendTail(append(info, format, channels), streamingSize)
+}
+
+// appendTail дописывает начало петли, число сэмплов, длительность, ссылки и размер данных
+func appendTail(info []byte, streamingSize uint32) []byte {
+	info = append(info, make([]byte, 4+4+4+12)...)
+	return binary.LittleEndian.AppendUint32(info, streamingSize)
+}
+
+var (
+	pcmData = []byte{1, 0, 2, 0, 3, 0, 4, 0}
+	mp3Data = []byte("\xff\xfb\x90\x64mp3 frame")
+	wavData = []byte("RIFF\x24\x00\x00\x00WAVEfmt ")
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint16
+		info    []byte
+		payload []byte
+		want    Audio
+	}{
+		{"v1 pcm16 stereo", 1, packedInfo(typeWAV, 16, 2, pcmFormat, 44100, 0), pcmData,
+			Audio{Format: FormatWAV, SampleRate: 44100, Channels: 2, Bits: 16, Data: pcmData}},
+		{"v0 pcm8 mono", 0, packedInfo(typeWAV, 8, 1, pcmFormat, 22050, 0), pcmData,
+			Audio{Format: FormatWAV, SampleRate: 22050, Channels: 1, Bits: 8, Data: pcmData}},
+		{"v3 max sample rate", 3, packedInfo(typeWAV, 16, 1, pcmFormat, 1<<17-1, 0), pcmData,
+			Audio{Format: FormatWAV, SampleRate: 1<<17 - 1, Channels: 1, Bits: 16, Data: pcmData}},
+		{"v2 mp3", 2, packedInfo(typeMP3, 0, 2, 0, 48000, 0), mp3Data,
+			Audio{Format: FormatMP3, SampleRate: 48000, Channels: 2, Data: mp3Data}},
+		{"v1 aac", 1, packedInfo(typeAAC, 0, 2, 0, 48000, 0), []byte("aac"),
+			Audio{Format: FormatAAC, SampleRate: 48000, Channels: 2, Data: []byte("aac")}},
+		{"v1 wav with header", 1, packedInfo(typeWAV, 16, 2, pcmFormat, 44100, 0), wavData,
+			Audio{Format: FormatWAV, SampleRate: 44100, Channels: 2, Bits: 16, Data: wavData, hasHeader: true}},
+		{"v1 streaming size", 1, packedInfo(typeWAV, 16, 1, pcmFormat, 44100, 4), append(pcmData, "trailer"...),
+			Audio{Format: FormatWAV, SampleRate: 44100, Channels: 1, Bits: 16, Data: pcmData[:4]}},
+
+		{"v4 pcm16", 4, v4Info(44100, v4PCM16, 2, 0), pcmData,
+			Audio{Format: FormatWAV, SampleRate: 44100, Channels: 2, Bits: 16, Data: pcmData}},
+		{"v4 pcm8", 4, v4Info(11025, v4PCM8, 1, 0), pcmData,
+			Audio{Format: FormatWAV, SampleRate: 11025, Channels: 1, Bits: 8, Data: pcmData}},
+		{"v4 mp3", 4, v4Info(44100, v4MP3, 2, uint32(len(mp3Data))), mp3Data,
+			Audio{Format: FormatMP3, SampleRate: 44100, Channels: 2, Data: mp3Data}},
+
+		{"kv3 wav", 5, []byte("\x03VK3 keyvalues"), wavData, Audio{Format: FormatWAV, Data: wavData, hasHeader: true}},
+		{"kv3 id3", 5, []byte("VKV\x03 keyvalues"), []byte("ID3\x04mp3"), Audio{Format: FormatMP3, Data: []byte("ID3\x04mp3")}},
+		{"kv3 mp3 frame", 5, []byte("KV3 keyvalues"), mp3Data, Audio{Format: FormatMP3, Data: mp3Data}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(buildVSnd(tt.version, tt.info, tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format != tt.want.Format || got.SampleRate != tt.want.SampleRate ||
+				got.Channels != tt.want.Channels || got.Bits != tt.want.Bits ||
+				got.hasHeader != tt.want.hasHeader || !bytes.Equal(got.Data, tt.want.Data) {
+				t.Errorf("Decode = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint16
+		info    []byte
+		payload []byte
+		want    error
+	}{
+		{"v1 adpcm", 1, packedInfo(typeWAV, 4, 1, 2, 44100, 0), pcmData, ErrUnsupportedFormat},
+		{"v1 unknown type", 1, packedInfo(3, 16, 1, pcmFormat, 44100, 0), pcmData, ErrUnsupportedFormat},
+		{"v1 no channels", 1, packedInfo(typeWAV, 16, 0, pcmFormat, 44100, 0), pcmData, ErrUnsupportedFormat},
+		{"v1 24 bit", 1, packedInfo(typeWAV, 24, 2, pcmFormat, 44100, 0), pcmData, ErrUnsupportedFormat},
+		{"v4 adpcm", 4, v4Info(44100, v4ADPCM, 1, 0), pcmData, ErrUnsupportedFormat},
+		{"v4 unknown format", 4, v4Info(44100, 9, 1, 0), pcmData, ErrUnsupportedFormat},
+		{"v4 no sample rate", 4, v4Info(0, v4PCM16, 1, 0), pcmData, ErrUnsupportedFormat},
+		{"binary v5", 5, v4Info(44100, v4PCM16, 1, 0), pcmData, ErrUnsupportedFormat},
+		{"kv3 unknown data", 5, []byte("\x03VK3"), []byte("OggS"), ErrUnsupportedFormat},
+
+		{"v1 truncated packed", 1, []byte{1, 2}, pcmData, resource.ErrCorrupt},
+		{"v1 truncated tail", 1, packedInfo(typeWAV, 16, 1, pcmFormat, 44100, 0)[:20], pcmData, resource.ErrCorrupt},
+		{"v4 truncated", 4, v4Info(44100, v4PCM16, 1, 0)[:3], pcmData, resource.ErrCorrupt},
+		{"streaming size past end", 4, v4Info(44100, v4PCM16, 1, 100), pcmData, resource.ErrCorrupt},
+		{"no data", 4, v4Info(44100, v4MP3, 1, 0), nil, resource.ErrCorrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(buildVSnd(tt.version, tt.info, tt.payload)); !errors.Is(err, tt.want) {
+				t.Fatalf("Decode error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteToWAV(t *testing.T) {
+	audio, err := Decode(buildVSnd(1, packedInfo(typeWAV, 16, 2, pcmFormat, 44100, 0), pcmData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := audio.WriteTo(&buf)
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("WriteTo = %d, %v; wrote %d", n, err, buf.Len())
+	}
+	out := buf.Bytes()
+	if len(out) != 44+len(pcmData) || !bytes.Equal(out[44:], pcmData) {
+		t.Fatalf("WriteTo wrote %d bytes, want a 44-byte header and the pcm data", len(out))
+	}
+
+	le := binary.LittleEndian
+	fields := []struct {
+		name      string
+		got, want uint32
+	}{
+		{"riff size", le.Uint32(out[4:]), uint32(36 + len(pcmData))},
+		{"fmt size", le.Uint32(out[16:]), 16},
+		{"format", uint32(le.Uint16(out[20:])), pcmFormat},
+		{"channels", uint32(le.Uint16(out[22:])), 2},
+		{"sample rate", le.Uint32(out[24:]), 44100},
+		{"byte rate", le.Uint32(out[28:]), 44100 * 4},
+		{"block align", uint32(le.Uint16(out[32:])), 4},
+		{"bits", uint32(le.Uint16(out[34:])), 16},
+		{"data size", le.Uint32(out[40:]), uint32(len(pcmData))},
+	}
+	for _, f := range fields {
+		if f.got != f.want {
+			t.Errorf("%s = %d, want %d", f.name, f.got, f.want)
+		}
+	}
+	for at, tag := range map[int]string{0: "RIFF", 8: "WAVE", 12: "fmt ", 36: "data"} {
+		if string(out[at:at+4]) != tag {
+			t.Errorf("tag at %d = %q, want %q", at, out[at:at+4], tag)
+		}
+	}
+}
+
+func TestWriteToPassesThrough(t *testing.T) {
+	// MP3 и готовый WAV пишутся без нового заголовка
+	tests := []struct {
+		name    string
+		version uint16
+		info    []byte
+		payload []byte
+		ext     string
+	}{
+		{"mp3", 4, v4Info(44100, v4MP3, 2, 0), mp3Data, ".mp3"},
+		{"wav with header", 1, packedInfo(typeWAV, 16, 2, pcmFormat, 44100, 0), wavData, ".wav"},
+		{"aac", 1, packedInfo(typeAAC, 0, 2, 0, 48000, 0), []byte("aac"), ".aac"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audio, err := Decode(buildVSnd(tt.version, tt.info, tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err := audio.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.payload) {
+				t.Errorf("WriteTo = %q, want %q", buf.Bytes(), tt.payload)
+			}
+			if ext := audio.Format.Ext(); ext != tt.ext {
+				t.Errorf("Ext() = %s, want %s", ext, tt.ext)
+			}
+		})
+	}
+}
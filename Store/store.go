@@ -0,0 +1,339 @@
+package store
+
+import (
+	addons "DeadlockHelper/Addons"
+	config "DeadlockHelper/Config"
+	"DeadlockHelper/internal/fsutil"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Хранилище держит каждый установленный VPK ровно один раз под именем его SHA-256:
+//
+//	~/.deadlockhelper/store/ab/ab12…ef.vpk
+//
+// В addons лежат жёсткие ссылки на эти файлы (или копии, если addons на другом диске),
+// поэтому переустановка мода и повторная загрузка того же файла не требуют ни скачивания,
+// ни копирования сотен мегабайт.
+
+var ErrNotInStore = errors.New("file not in store")
+
+const indexFileName = "index.json"
+
+// Dir возвращает папку хранилища внутри папки настроек
+func Dir() (string, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(configDir, "store")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// Path возвращает путь к файлу с хешем hash в хранилище
+func Path(hash string) (string, error) {
+	if len(hash) != sha256.Size*2 || strings.Trim(hash, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid hash %q", hash)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, hash[:2], hash+".vpk"), nil
+}
+
+// Has сообщает, есть ли в хранилище файл с хешем hash
+func Has(hash string) bool {
+	p, err := Path(hash)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(p)
+	return err == nil
+}
+
+// HashFile считает SHA-256 файла
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// AddFile кладёт в хранилище копию (или жёсткую ссылку на) файл path и возвращает его хеш
+func AddFile(path string) (string, error) {
+	hash, err := HashFile(path)
+	if err != nil {
+		return "", err
+	}
+	dst, err := Path(hash)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	if err := linkOrCopy(path, dst); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+	return hash, nil
+}
+
+// Adopt переносит файл tmpPath с хешем hash в хранилище и возвращает его новый путь.
+// tmpPath должен лежать на том же диске, что и хранилище. Если такой файл в хранилище
+// уже есть, tmpPath просто удаляется.
+func Adopt(tmpPath, hash string) (string, error) {
+	dst, err := Path(hash)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		os.Remove(tmpPath)
+		return dst, nil
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// Remove удаляет файл с хешем hash из хранилища
+func Remove(hash string) error {
+	p, err := Path(hash)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Link создаёт в dst жёсткую ссылку на файл из хранилища, а если это невозможно
+// (например, addons на другом диске) — его копию. dst не должен существовать.
+func Link(hash, dst string) error {
+	src, err := Path(hash)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("%w: %s", ErrNotInStore, hash)
+	}
+	return linkOrCopy(src, dst)
+}
+
+// Install ставит файл из хранилища в следующий свободный слот addonsDir
+func Install(hash, addonsDir string) (string, error) {
+	if _, err := Path(hash); err != nil {
+		return "", err
+	}
+	tmpPath := filepath.Join(addonsDir, ".store-"+hash[:16]+".tmp")
+	os.Remove(tmpPath) // остаток прерванной установки
+	if err := Link(hash, tmpPath); err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpPath) // после успешного переименования файла уже нет
+	return addons.Install(tmpPath, addonsDir)
+}
+
+// linkOrCopy делает жёсткую ссылку, а при неудаче копирует файл через временный,
+// чтобы в dst никогда не оказался недописанный файл
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	} else if errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".copy-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s: %w", dst, fs.ErrExist)
+	}
+	return os.Rename(tmpPath, dst)
+}
+
+// Remember запоминает, что скачанный с GameBanana файл с MD5 downloadMD5 дал VPK
+// с хешем hash. По этой записи повторная установка того же файла обходится без загрузки.
+func Remember(downloadMD5, hash string) error {
+	if downloadMD5 == "" || hash == "" {
+		return nil
+	}
+	return updateIndex(func(index map[string]string) {
+		index[strings.ToLower(downloadMD5)] = hash
+	})
+}
+
+// Lookup возвращает хеш VPK, полученного из файла GameBanana с MD5 downloadMD5,
+// если этот VPK всё ещё лежит в хранилище
+func Lookup(downloadMD5 string) (string, bool) {
+	if downloadMD5 == "" {
+		return "", false
+	}
+	index, err := loadIndex()
+	if err != nil {
+		return "", false
+	}
+	hash, ok := index[strings.ToLower(downloadMD5)]
+	if !ok || !Has(hash) {
+		return "", false
+	}
+	return hash, true
+}
+
+// Prune удаляет из хранилища файлы, хешей которых нет в keep, и возвращает число
+// освобождённых байт. Файл, на который ещё ссылается addons, освобождает место
+// только после удаления ссылки.
+func Prune(keep map[string]bool) (int64, error) {
+	dir, err := Dir()
+	if err != nil {
+		return 0, err
+	}
+
+	var freed int64
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".vpk") {
+			return nil
+		}
+		if keep[strings.TrimSuffix(d.Name(), ".vpk")] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		return freed, err
+	}
+
+	// Записи индекса на удалённые файлы больше не нужны
+	return freed, updateIndex(func(index map[string]string) {
+		for md5, hash := range index {
+			if !keep[hash] {
+				delete(index, md5)
+			}
+		}
+	})
+}
+
+// Индекс правят параллельные загрузки и иногда несколько копий программы, поэтому
+// каждое изменение идёт под мьютексом и блокировкой файла index.json.lock, а сам
+// индекс заменяется атомарно: читатели (Lookup) видят либо старый, либо новый файл.
+
+// indexMu сериализует правки индекса внутри процесса: flock на разных дескрипторах
+// одного процесса тоже конфликтует
+var indexMu sync.Mutex
+
+// updateIndex читает индекс, передаёт его в fn и сохраняет, не отпуская замков
+func updateIndex(fn func(index map[string]string)) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(dir, indexFileName+".lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open store index lock: %w", err)
+	}
+	defer f.Close()
+	if err := fsutil.LockFile(f); err != nil {
+		return fmt.Errorf("failed to lock store index: %w", err)
+	}
+	defer fsutil.UnlockFile(f)
+
+	index, err := loadIndex()
+	if err != nil {
+		return err
+	}
+	fn(index)
+	return saveIndex(index)
+}
+
+func loadIndex() (map[string]string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("store index is corrupted: %w", err)
+	}
+	return index, nil
+}
+
+func saveIndex(index map[string]string) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(dir, indexFileName), data)
+}
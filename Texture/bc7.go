@@ -0,0 +1,242 @@
+package texture
+
+import "image"
+
+// BC7 — 16 байт на блок 4×4. Номер режима записан унарным кодом в младших битах,
+// режим задаёт число подмножеств пикселей (у каждого своя пара опорных цветов),
+// точность цветов и альфы, p-биты и разрядность индексов.
+
+type bc7Mode struct {
+	subsets        int
+	partitionBits  int
+	rotationBits   int
+	indexSelection int
+	colorBits      int
+	alphaBits      int
+	endpointPBits  bool // свой p-бит у каждого опорного цвета
+	sharedPBits    bool // один p-бит на подмножество
+	indexBits      int
+	index2Bits     int // вторые индексы (отдельно для альфы) в режимах 4 и 5
+}
+
+var bc7Modes = [8]bc7Mode{
+	{subsets: 3, partitionBits: 4, colorBits: 4, endpointPBits: true, indexBits: 3},
+	{subsets: 2, partitionBits: 6, colorBits: 6, sharedPBits: true, indexBits: 3},
+	{subsets: 3, partitionBits: 6, colorBits: 5, indexBits: 2},
+	{subsets: 2, partitionBits: 6, colorBits: 7, endpointPBits: true, indexBits: 2},
+	{subsets: 1, rotationBits: 2, indexSelection: 1, colorBits: 5, alphaBits: 6, indexBits: 2, index2Bits: 3},
+	{subsets: 1, rotationBits: 2, colorBits: 7, alphaBits: 8, indexBits: 2, index2Bits: 2},
+	{subsets: 1, colorBits: 7, alphaBits: 7, endpointPBits: true, indexBits: 4},
+	{subsets: 2, partitionBits: 6, colorBits: 5, alphaBits: 5, endpointPBits: true, indexBits: 2},
+}
+
+// bc7Partitions2 — разбиения на два подмножества: бит i — подмножество пикселя i
+var bc7Partitions2 = [64]uint16{
+	0xCCCC, 0x8888, 0xEEEE, 0xECC8, 0xC880, 0xFEEC, 0xFEC8, 0xEC80,
+	0xC800, 0xFFEC, 0xFE80, 0xE800, 0xFFE8, 0xFF00, 0xFFF0, 0xF000,
+	0xF710, 0x008E, 0x7100, 0x08CE, 0x008C, 0x7310, 0x3100, 0x8CCE,
+	0x088C, 0x3110, 0x6666, 0x366C, 0x17E8, 0x0FF0, 0x718E, 0x399C,
+	0xAAAA, 0xF0F0, 0x5A5A, 0x33CC, 0x3C3C, 0x55AA, 0x9696, 0xA55A,
+	0x73CE, 0x13C8, 0x324C, 0x3BDC, 0x6996, 0xC33C, 0x9966, 0x0660,
+	0x0272, 0x04E4, 0x4E40, 0x2720, 0xC936, 0x936C, 0x39C6, 0x639C,
+	0x9336, 0x9CC6, 0x817E, 0xE718, 0xCCF0, 0x0FCC, 0x7744, 0xEE22,
+}
+
+// bc7Partitions3 — разбиения на три подмножества: два бита на пиксель
+var bc7Partitions3 = [64]uint32{
+	0xAA685050, 0x6A5A5040, 0x5A5A4200, 0x5450A0A8, 0xA5A50000, 0xA0A05050, 0x5555A0A0, 0x5A5A5050,
+	0xAA550000, 0xAA555500, 0xAAAA5500, 0x90909090, 0x94949494, 0xA4A4A4A4, 0xA9A59450, 0x2A0A4250,
+	0xA5945040, 0x0A425054, 0xA5A5A500, 0x55A0A0A0, 0xA8A85454, 0x6A6A4040, 0xA4A45000, 0x1A1A0500,
+	0x0050A4A4, 0xAAA59090, 0x14696914, 0x69691400, 0xA08585A0, 0xAA821414, 0x50A4A450, 0x6A5A0200,
+	0xA9A58000, 0x5090A0A8, 0xA8A09050, 0x24242424, 0x00AA5500, 0x24924924, 0x24499224, 0x50A50A50,
+	0x500AA550, 0xAAAA4444, 0x66660000, 0xA5A0A5A0, 0x50A050A0, 0x69286928, 0x44AAAA44, 0x66666600,
+	0xAA444444, 0x54A854A8, 0x95809580, 0x96969600, 0xA85454A8, 0x80959580, 0xAA141414, 0x96960000,
+	0xAAAA1414, 0xA05050A0, 0xA0A5A5A0, 0x96000000, 0x40804080, 0xA9A8A9A8, 0xAAAAAA44, 0x2A4A5254,
+}
+
+// Опорные пиксели подмножеств: их индекс хранится на один бит короче.
+// У первого подмножества опорный пиксель всегда нулевой.
+var bc7Anchors2 = [64]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 2, 8, 2, 2, 8, 8, 15, 2, 8, 2, 2, 8, 8, 2, 2,
+	15, 15, 6, 8, 2, 8, 15, 15, 2, 8, 2, 2, 2, 15, 15, 6,
+	6, 2, 6, 8, 15, 15, 2, 2, 15, 15, 15, 15, 15, 2, 2, 15,
+}
+
+var bc7Anchors3a = [64]uint8{
+	3, 3, 15, 15, 8, 3, 15, 15, 8, 8, 6, 6, 6, 5, 3, 3,
+	3, 3, 8, 15, 3, 3, 6, 10, 5, 8, 8, 6, 8, 5, 15, 15,
+	8, 15, 3, 5, 6, 10, 8, 15, 15, 3, 15, 5, 15, 15, 15, 15,
+	3, 15, 5, 5, 5, 8, 5, 10, 5, 10, 8, 13, 15, 12, 3, 3,
+}
+
+var bc7Anchors3b = [64]uint8{
+	15, 8, 8, 3, 15, 15, 3, 8, 15, 15, 15, 15, 15, 15, 15, 8,
+	15, 8, 15, 3, 15, 8, 15, 8, 3, 15, 6, 10, 15, 15, 10, 8,
+	15, 3, 15, 10, 10, 8, 9, 10, 6, 15, 8, 15, 3, 6, 6, 8,
+	15, 3, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 3, 15, 15, 8,
+}
+
+var bc7Weights = [5][]int{
+	2: {0, 21, 43, 64},
+	3: {0, 9, 18, 27, 37, 46, 55, 64},
+	4: {0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64},
+}
+
+func decodeBC7(data []byte, width, height int) (image.Image, error) {
+	return decodeBlocks(data, width, height, 16, bc7Block), nil
+}
+
+// bitReader читает биты блока начиная с младшего
+type bitReader struct {
+	lo, hi uint64
+}
+
+func (r *bitReader) read(n int) int {
+	v := r.lo & (1<<n - 1)
+	r.lo = r.lo>>n | r.hi<<(64-n)
+	r.hi >>= n
+	return int(v)
+}
+
+func bc7Block(block []byte, out *[16][4]uint8) {
+	r := bitReader{}
+	for i := 7; i >= 0; i-- {
+		r.lo = r.lo<<8 | uint64(block[i])
+		r.hi = r.hi<<8 | uint64(block[8+i])
+	}
+
+	mode := 0
+	for mode < 8 && r.read(1) == 0 {
+		mode++
+	}
+	if mode == 8 {
+		// Зарезервированный режим декодируется как прозрачный чёрный
+		*out = [16][4]uint8{}
+		return
+	}
+	m := bc7Modes[mode]
+
+	partition := r.read(m.partitionBits)
+	rotation := r.read(m.rotationBits)
+	indexSelection := r.read(m.indexSelection)
+
+	// Опорные цвета: сначала R всех точек, затем G, B и альфа
+	var endpoints [6][4]int
+	points := m.subsets * 2
+	for ch := 0; ch < 3; ch++ {
+		for p := 0; p < points; p++ {
+			endpoints[p][ch] = r.read(m.colorBits)
+		}
+	}
+	for p := 0; p < points; p++ {
+		endpoints[p][3] = r.read(m.alphaBits)
+	}
+
+	colorBits, alphaBits := m.colorBits, m.alphaBits
+	if m.endpointPBits || m.sharedPBits {
+		var pbits [6]int
+		if m.endpointPBits {
+			for p := 0; p < points; p++ {
+				pbits[p] = r.read(1)
+			}
+		} else {
+			for s := 0; s < m.subsets; s++ {
+				bit := r.read(1)
+				pbits[2*s], pbits[2*s+1] = bit, bit
+			}
+		}
+		for p := 0; p < points; p++ {
+			for ch := 0; ch < 4; ch++ {
+				endpoints[p][ch] = endpoints[p][ch]<<1 | pbits[p]
+			}
+		}
+		colorBits++
+		if alphaBits > 0 {
+			alphaBits++
+		}
+	}
+	for p := 0; p < points; p++ {
+		for ch := 0; ch < 3; ch++ {
+			endpoints[p][ch] = expandBits(endpoints[p][ch], colorBits)
+		}
+		if alphaBits > 0 {
+			endpoints[p][3] = expandBits(endpoints[p][3], alphaBits)
+		} else {
+			endpoints[p][3] = 255
+		}
+	}
+
+	var subset [16]int
+	anchors := [3]int{0, 0, 0}
+	switch m.subsets {
+	case 2:
+		for i := range subset {
+			subset[i] = int(bc7Partitions2[partition] >> i & 1)
+		}
+		anchors[1] = int(bc7Anchors2[partition])
+	case 3:
+		for i := range subset {
+			subset[i] = int(bc7Partitions3[partition] >> (2 * i) & 3)
+		}
+		anchors[1] = int(bc7Anchors3a[partition])
+		anchors[2] = int(bc7Anchors3b[partition])
+	}
+	isAnchor := func(i int) bool {
+		return i == anchors[subset[i]]
+	}
+
+	var indices, indices2 [16]int
+	for i := range indices {
+		bits := m.indexBits
+		if isAnchor(i) {
+			bits--
+		}
+		indices[i] = r.read(bits)
+	}
+	if m.index2Bits > 0 {
+		for i := range indices2 {
+			bits := m.index2Bits
+			if i == 0 {
+				bits--
+			}
+			indices2[i] = r.read(bits)
+		}
+	}
+
+	colorWeights, alphaWeights := bc7Weights[m.indexBits], bc7Weights[m.indexBits]
+	colorIndex, alphaIndex := &indices, &indices
+	if m.index2Bits > 0 {
+		alphaWeights, alphaIndex = bc7Weights[m.index2Bits], &indices2
+		if indexSelection == 1 {
+			colorWeights, alphaWeights = alphaWeights, colorWeights
+			colorIndex, alphaIndex = alphaIndex, colorIndex
+		}
+	}
+
+	for i := range out {
+		e0, e1 := endpoints[2*subset[i]], endpoints[2*subset[i]+1]
+		cw, aw := colorWeights[colorIndex[i]], alphaWeights[alphaIndex[i]]
+		var px [4]uint8
+		for ch := 0; ch < 3; ch++ {
+			px[ch] = uint8(((64-cw)*e0[ch] + cw*e1[ch] + 32) >> 6)
+		}
+		px[3] = uint8(((64-aw)*e0[3] + aw*e1[3] + 32) >> 6)
+
+		switch rotation {
+		case 1:
+			px[0], px[3] = px[3], px[0]
+		case 2:
+			px[1], px[3] = px[3], px[1]
+		case 3:
+			px[2], px[3] = px[3], px[2]
+		}
+		out[i] = px
+	}
+}
+
+// expandBits растягивает значение из bits бит до 8, повторяя старшие биты в младших
+func expandBits(v, bits int) int {
+	v <<= 8 - bits
+	return v | v>>bits
+}
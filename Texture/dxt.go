@@ -0,0 +1,102 @@
+package texture
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// Блочное сжатие: картинка делится на блоки 4×4, каждый блок хранится отдельно.
+// DXT1 (BC1) — 8 байт на блок: два цвета RGB565 и 2-битный индекс на пиксель.
+// DXT5 (BC3) — 16 байт: блок альфы (два значения и 3-битные индексы) и блок DXT1.
+
+// decodeBlocks проходит по блокам картинки и раскладывает пиксели, которые
+// вернул decode, с учётом того, что крайние блоки могут выходить за её границы
+func decodeBlocks(data []byte, width, height, blockBytes int, decode func(block []byte, out *[16][4]uint8)) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	var px [16][4]uint8
+	bw, bh := blocks(width), blocks(height)
+	for by := 0; by < bh; by++ {
+		for bx := 0; bx < bw; bx++ {
+			at := (by*bw + bx) * blockBytes
+			decode(data[at:at+blockBytes], &px)
+			for i, c := range px {
+				x, y := bx*4+i%4, by*4+i/4
+				if x < width && y < height {
+					copy(img.Pix[img.PixOffset(x, y):], c[:])
+				}
+			}
+		}
+	}
+	return img
+}
+
+func decodeDXT1(data []byte, width, height int) (image.Image, error) {
+	return decodeBlocks(data, width, height, 8, func(block []byte, out *[16][4]uint8) {
+		colorBlock(block, out, true)
+	}), nil
+}
+
+func decodeDXT5(data []byte, width, height int) (image.Image, error) {
+	return decodeBlocks(data, width, height, 16, func(block []byte, out *[16][4]uint8) {
+		colorBlock(block[8:], out, false)
+		alphaBlock(block[:8], out)
+	}), nil
+}
+
+// colorBlock декодирует цветовую часть блока BC1. Режим с прозрачным чёрным
+// (color0 <= color1) бывает только в самом DXT1.
+func colorBlock(block []byte, out *[16][4]uint8, allowAlpha bool) {
+	c0 := binary.LittleEndian.Uint16(block[0:])
+	c1 := binary.LittleEndian.Uint16(block[2:])
+	var palette [4][4]uint8
+	palette[0], palette[1] = rgb565(c0), rgb565(c1)
+	for ch := 0; ch < 3; ch++ {
+		a, b := int(palette[0][ch]), int(palette[1][ch])
+		if c0 > c1 || !allowAlpha {
+			palette[2][ch] = uint8((2*a + b) / 3)
+			palette[3][ch] = uint8((a + 2*b) / 3)
+		} else {
+			palette[2][ch] = uint8((a + b) / 2)
+		}
+	}
+	palette[2][3] = 255
+	if c0 > c1 || !allowAlpha {
+		palette[3][3] = 255
+	}
+
+	indices := binary.LittleEndian.Uint32(block[4:])
+	for i := range out {
+		out[i] = palette[indices>>(2*i)&3]
+	}
+}
+
+// alphaBlock декодирует альфу блока BC3 поверх уже разложенных цветов
+func alphaBlock(block []byte, out *[16][4]uint8) {
+	a0, a1 := int(block[0]), int(block[1])
+	var alpha [8]uint8
+	alpha[0], alpha[1] = uint8(a0), uint8(a1)
+	if a0 > a1 {
+		for i := 1; i <= 6; i++ {
+			alpha[i+1] = uint8(((7-i)*a0 + i*a1) / 7)
+		}
+	} else {
+		for i := 1; i <= 4; i++ {
+			alpha[i+1] = uint8(((5-i)*a0 + i*a1) / 5)
+		}
+		alpha[6], alpha[7] = 0, 255
+	}
+
+	var bits uint64
+	for i := 0; i < 6; i++ {
+		bits |= uint64(block[2+i]) << (8 * i)
+	}
+	for i := range out {
+		out[i][3] = alpha[bits>>(3*i)&7]
+	}
+}
+
+// rgb565 раскладывает цвет RGB565 в 8-битные каналы
+func rgb565(c uint16) [4]uint8 {
+	r, g, b := uint8(c>>11&31), uint8(c>>5&63), uint8(c&31)
+	return [4]uint8{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
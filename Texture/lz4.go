@@ -0,0 +1,81 @@
+package texture
+
+import "errors"
+
+// Мипы куба и массива текстур сжаты одним блоком LZ4 на все грани, а превью нужна
+// только первая. Обычная распаковка блока требует буфер под все грани, поэтому
+// здесь свой разбор формата блока, который останавливается, как только dst
+// заполнен.
+
+// maxLZ4Ratio — во сколько раз блок LZ4 может быть больше сжатого: одна
+// последовательность из 255-байтовых продолжений длины даёт чуть меньше 255 байт
+// на байт входа
+const maxLZ4Ratio = 255
+
+var errLZ4 = errors.New("invalid lz4 block")
+
+// uncompressLZ4Prefix распаковывает начало блока LZ4 src в dst и возвращает, сколько
+// байт записано. Данные блока после len(dst) байт не читаются.
+func uncompressLZ4Prefix(src, dst []byte) (int, error) {
+	var i, d int
+	// length дочитывает продолжение длины: байты 255 прибавляются, пока не встретится меньший
+	length := func(n int) (int, error) {
+		for {
+			if i >= len(src) {
+				return 0, errLZ4
+			}
+			b := int(src[i])
+			i++
+			n += b
+			if b != 255 {
+				return n, nil
+			}
+		}
+	}
+
+	for d < len(dst) {
+		if i >= len(src) {
+			return d, nil
+		}
+		token := src[i]
+		i++
+
+		literals := int(token >> 4)
+		if literals == 15 {
+			var err error
+			if literals, err = length(literals); err != nil {
+				return d, err
+			}
+		}
+		if literals > len(src)-i {
+			return d, errLZ4
+		}
+		d += copy(dst[d:], src[i:i+literals])
+		i += literals
+		if d == len(dst) || i == len(src) {
+			return d, nil // последняя последовательность состоит из одних литералов
+		}
+
+		if i+2 > len(src) {
+			return d, errLZ4
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > d {
+			return d, errLZ4
+		}
+		match := int(token & 15)
+		if match == 15 {
+			var err error
+			if match, err = length(match); err != nil {
+				return d, err
+			}
+		}
+		// Совпадение может перекрывать само себя, поэтому копируем побайтно
+		for n := match + 4; n > 0 && d < len(dst); n-- {
+			dst[d] = dst[d-offset]
+			d++
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,167 @@
+package texture
+
+import (
+	vpk "DeadlockHelper/VPK"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// CellSize — размер клетки контактного листа в пикселях
+	CellSize = 256
+	// MaxSheetTextures — сколько текстур попадает на контактный лист
+	MaxSheetTextures = 9
+
+	minTextureSize = 32        // иконки и заглушки меньше этого не показываем
+	headerReadSize = 64 * 1024 // заголовок и блок DATA всегда в начале файла
+)
+
+var ErrNoTextures = errors.New("no decodable textures in vpk")
+
+// Служебные карты выглядят на превью как шум, поэтому идут в конец
+var auxiliarySuffixes = []string{"_normal", "_rough", "_ao", "_mask", "_metal", "_tint", "_selfillum", "_trans", "_height"}
+
+type candidate struct {
+	entry vpk.Entry
+	rank  int
+	area  int
+}
+
+// ContactSheet собирает из текстур VPK контактный лист: до MaxSheetTextures
+// картинок в клетках CellSize×CellSize. Сначала берутся цветовые текстуры и
+// картинки интерфейса, внутри группы — самые большие. Текстуры в неизвестных
+// форматах пропускаются.
+func ContactSheet(vpkPath string) (image.Image, error) {
+	archive, err := vpk.Open(vpkPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []candidate
+	for _, e := range archive.Entries {
+		if !strings.HasSuffix(e.Path, ".vtex_c") {
+			continue
+		}
+		h, err := readEntryHeader(archive, e)
+		if err != nil || h.Width < minTextureSize || h.Height < minTextureSize {
+			continue
+		}
+		if _, ok := decoders[h.Format]; !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{entry: e, rank: textureRank(e.Path), area: h.Width * h.Height})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].rank != candidates[j].rank {
+			return candidates[i].rank < candidates[j].rank
+		}
+		return candidates[i].area > candidates[j].area
+	})
+
+	var images []image.Image
+	for _, c := range candidates {
+		if len(images) == MaxSheetTextures {
+			break
+		}
+		data, err := archive.ReadFile(c.entry.Path)
+		if err != nil {
+			continue
+		}
+		img, err := Decode(data)
+		if err != nil {
+			fmt.Println("Failed to decode texture:", c.entry.Path, err)
+			continue
+		}
+		images = append(images, img)
+	}
+	if len(images) == 0 {
+		return nil, ErrNoTextures
+	}
+	return layoutSheet(images), nil
+}
+
+// WriteContactSheet собирает контактный лист VPK и сохраняет его в PNG outPath
+func WriteContactSheet(vpkPath, outPath string) error {
+	sheet, err := ContactSheet(vpkPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), ".sheet-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // после переименования файла уже нет
+	if err := png.Encode(tmp, sheet); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outPath)
+}
+
+// readEntryHeader читает только начало текстуры, где лежит её описание
+func readEntryHeader(archive *vpk.Archive, e vpk.Entry) (Header, error) {
+	rc, err := archive.OpenEntry(e)
+	if err != nil {
+		return Header{}, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, headerReadSize))
+	if err != nil {
+		return Header{}, err
+	}
+	h, _, err := ReadHeader(data)
+	return h, err
+}
+
+// textureRank упорядочивает текстуры по тому, насколько они наглядны на превью
+func textureRank(p string) int {
+	name := strings.TrimSuffix(filepath.Base(p), ".vtex_c")
+	switch {
+	case strings.HasPrefix(p, "panorama/images/"):
+		return 0
+	case strings.Contains(name, "_color"), strings.Contains(name, "albedo"), strings.Contains(name, "diffuse"):
+		return 0
+	}
+	for _, suffix := range auxiliarySuffixes {
+		if strings.Contains(name, suffix) {
+			return 2
+		}
+	}
+	return 1
+}
+
+// layoutSheet раскладывает картинки по клеткам почти квадратной сетки, вписывая
+// каждую в клетку с сохранением пропорций
+func layoutSheet(images []image.Image) image.Image {
+	columns := int(math.Ceil(math.Sqrt(float64(len(images)))))
+	rows := (len(images) + columns - 1) / columns
+	sheet := image.NewNRGBA(image.Rect(0, 0, columns*CellSize, rows*CellSize))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.NRGBA{32, 32, 32, 255}), image.Point{}, draw.Src)
+
+	for i, img := range images {
+		b := img.Bounds()
+		scale := math.Min(float64(CellSize)/float64(b.Dx()), float64(CellSize)/float64(b.Dy()))
+		w, h := int(float64(b.Dx())*scale), int(float64(b.Dy())*scale)
+		x := i%columns*CellSize + (CellSize-w)/2
+		y := i/columns*CellSize + (CellSize-h)/2
+		draw.CatmullRom.Scale(sheet, image.Rect(x, y, x+w, y+h), img, b, draw.Over, nil)
+	}
+	return sheet
+}
@@ -0,0 +1,241 @@
+package texture
+
+import (
+	resource "DeadlockHelper/Resource"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+)
+
+// Блок DATA скомпилированной текстуры (*.vtex_c):
+//
+//	версия, флаги, отражательная способность (4 float), ширина, высота, глубина,
+//	формат, число мипов, разрешение picmip0, смещение и число записей доп. данных
+//
+// Сами мипы лежат сразу после блока DATA, от самого маленького к самому большому.
+// Если среди доп. данных есть размеры сжатых мипов, каждый мип сжат LZ4.
+
+// Format — формат пикселей текстуры
+type Format uint8
+
+const (
+	FormatDXT1     Format = 1
+	FormatDXT5     Format = 2
+	FormatRGBA8888 Format = 4
+	FormatBC7      Format = 20
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatDXT1:
+		return "DXT1"
+	case FormatDXT5:
+		return "DXT5"
+	case FormatRGBA8888:
+		return "RGBA8888"
+	case FormatBC7:
+		return "BC7"
+	}
+	return fmt.Sprintf("format %d", uint8(f))
+}
+
+const (
+	vtexHeaderSize = 40
+	flagCube       = 0x10
+
+	extraCompressedMipSize = 4
+
+	// Ограничения размеров, чтобы испорченный заголовок не заставил выделить
+	// гигабайты памяти под мип. Стороны текстур Source 2 не больше 16384, но
+	// декодируется только первая грань, и для превью хватает 4096×4096.
+	maxTextureSide  = 16384
+	maxTexels       = 1 << 24 // ширина × высота одной грани
+	maxStoredTexels = 1 << 28 // ширина × высота × грани и слои
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported texture format")
+
+// Header — описание текстуры из блока DATA
+type Header struct {
+	Version   uint16
+	Flags     uint16
+	Width     int
+	Height    int
+	Depth     int
+	Format    Format
+	MipLevels int
+
+	compressedMips []int // размер каждого мипа в файле, если они сжаты LZ4
+}
+
+// ReadHeader разбирает описание текстуры, не декодируя пиксели
+func ReadHeader(data []byte) (Header, *resource.Resource, error) {
+	res, err := resource.Parse(data)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	block, err := res.Data("DATA")
+	if err != nil {
+		return Header{}, nil, err
+	}
+	if len(block) < vtexHeaderSize {
+		return Header{}, nil, fmt.Errorf("%w: texture header is too short", resource.ErrCorrupt)
+	}
+
+	le := binary.LittleEndian
+	h := Header{
+		Version:   le.Uint16(block[0:]),
+		Flags:     le.Uint16(block[2:]),
+		Width:     int(le.Uint16(block[20:])),
+		Height:    int(le.Uint16(block[22:])),
+		Depth:     int(le.Uint16(block[24:])),
+		Format:    Format(block[26]),
+		MipLevels: int(block[27]),
+	}
+	if h.Width == 0 || h.Height == 0 {
+		return Header{}, nil, fmt.Errorf("%w: empty texture", resource.ErrCorrupt)
+	}
+	if h.Depth == 0 {
+		h.Depth = 1
+	}
+	if h.MipLevels == 0 {
+		h.MipLevels = 1
+	}
+	if h.Width > maxTextureSide || h.Height > maxTextureSide ||
+		h.Width*h.Height > maxTexels || h.Width*h.Height*h.faces(0) > maxStoredTexels {
+		return Header{}, nil, fmt.Errorf("%w: texture %dx%d with %d faces is too large",
+			resource.ErrCorrupt, h.Width, h.Height, h.faces(0))
+	}
+
+	// Доп. данные: тип, смещение от поля смещения, размер
+	extraOffset := 32 + uint64(le.Uint32(block[32:]))
+	extraCount := uint64(le.Uint32(block[36:]))
+	for i := uint64(0); i < extraCount; i++ {
+		at := extraOffset + i*12
+		if at+12 > uint64(len(block)) {
+			return Header{}, nil, fmt.Errorf("%w: texture extra data is outside the block", resource.ErrCorrupt)
+		}
+		if le.Uint32(block[at:]) != extraCompressedMipSize {
+			continue
+		}
+		start := at + 4 + uint64(le.Uint32(block[at+4:]))
+		size := uint64(le.Uint32(block[at+8:]))
+		if start+size > uint64(len(block)) || size < 12 {
+			return Header{}, nil, fmt.Errorf("%w: compressed mip sizes are outside the block", resource.ErrCorrupt)
+		}
+		count := uint64(le.Uint32(block[start+8:]))
+		if 12+count*4 > size {
+			return Header{}, nil, fmt.Errorf("%w: bad compressed mip sizes", resource.ErrCorrupt)
+		}
+		h.compressedMips = make([]int, count)
+		for m := range h.compressedMips {
+			h.compressedMips[m] = int(le.Uint32(block[start+12+uint64(m)*4:]))
+		}
+	}
+	return h, res, nil
+}
+
+// Decode декодирует самый большой мип текстуры (первую грань куба или первый
+// слой массива)
+func Decode(data []byte) (image.Image, error) {
+	h, res, err := ReadHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := decoders[h.Format]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, h.Format)
+	}
+
+	block, _ := res.Block("DATA")
+	offset := int(block.Offset + block.Size)
+
+	// Мипы идут от меньшего к большему, пропускаем все, кроме нулевого
+	for level := h.MipLevels - 1; level > 0; level-- {
+		offset += h.storedSize(level)
+	}
+	mip, err := h.readMip(data, offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	return decoders[h.Format](mip, h.Width, h.Height)
+}
+
+// faces возвращает число граней (6 у куба) и слоёв на уровне level
+func (h Header) faces(level int) int {
+	faces := 1
+	if h.Flags&flagCube != 0 {
+		faces = 6
+	}
+	return faces * max(1, h.Depth>>level)
+}
+
+// mipSize возвращает размер одного слоя мипа level без сжатия
+func (h Header) mipSize(level int) int {
+	w := max(1, h.Width>>level)
+	height := max(1, h.Height>>level)
+	switch h.Format {
+	case FormatDXT1:
+		return blocks(w) * blocks(height) * 8
+	case FormatDXT5, FormatBC7:
+		return blocks(w) * blocks(height) * 16
+	default:
+		return w * height * 4
+	}
+}
+
+// storedSize возвращает, сколько байт мип level занимает в файле
+func (h Header) storedSize(level int) int {
+	if level < len(h.compressedMips) {
+		return h.compressedMips[level]
+	}
+	return h.mipSize(level) * h.faces(level)
+}
+
+// readMip возвращает первый слой мипа level, при необходимости распаковывая LZ4.
+// Остальные грани и слои не распаковываются.
+func (h Header) readMip(data []byte, offset, level int) ([]byte, error) {
+	stored := h.storedSize(level)
+	if offset < 0 || offset+stored > len(data) {
+		return nil, fmt.Errorf("%w: mip %d is outside the file", resource.ErrCorrupt, level)
+	}
+	raw := data[offset : offset+stored]
+	size := h.mipSize(level)
+	if level < len(h.compressedMips) && stored < size*h.faces(level) {
+		// Буфер выделяется по заголовку, поэтому сначала проверяем, что сжатых
+		// данных вообще хватит на такой мип
+		if size > stored*maxLZ4Ratio {
+			return nil, fmt.Errorf("%w: mip %d: %d bytes cannot unpack to %d", resource.ErrCorrupt, level, stored, size)
+		}
+		out := make([]byte, size)
+		n, err := uncompressLZ4Prefix(raw, out)
+		if err != nil {
+			return nil, fmt.Errorf("%w: mip %d: %v", resource.ErrCorrupt, level, err)
+		}
+		raw = out[:n]
+	}
+	if len(raw) < size {
+		return nil, fmt.Errorf("%w: mip %d is truncated", resource.ErrCorrupt, level)
+	}
+	return raw[:size], nil
+}
+
+// blocks возвращает число блоков 4×4 на стороне длиной n пикселей
+func blocks(n int) int {
+	return (n + 3) / 4
+}
+
+type decoder func(data []byte, width, height int) (image.Image, error)
+
+var decoders = map[Format]decoder{
+	FormatDXT1:     decodeDXT1,
+	FormatDXT5:     decodeDXT5,
+	FormatRGBA8888: decodeRGBA8888,
+	FormatBC7:      decodeBC7,
+}
+
+func decodeRGBA8888(data []byte, width, height int) (image.Image, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	copy(img.Pix, data)
+	return img, nil
+}
@@ -0,0 +1,321 @@
+package texture
+
+import (
+	resource "DeadlockHelper/Resource"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// vtexSpec — параметры текстуры, которую собирает buildVTex
+type vtexSpec struct {
+	format        Format
+	width, height int
+	depth         int
+	flags         uint16
+	mips          []byte // данные мипов после блока DATA
+	compressed    []int  // размеры сжатых LZ4 мипов, если они сжаты
+}
+
+// buildVTex собирает ресурс с одним блоком DATA и мипами сразу после него
+func buildVTex(s vtexSpec) []byte {
+	le := binary.LittleEndian
+	block := make([]byte, vtexHeaderSize)
+	le.PutUint16(block[0:], 1)
+	le.PutUint16(block[2:], s.flags)
+	le.PutUint16(block[20:], uint16(s.width))
+	le.PutUint16(block[22:], uint16(s.height))
+	le.PutUint16(block[24:], uint16(s.depth))
+	block[26] = byte(s.format)
+	block[27] = 1
+	if len(s.compressed) > 0 {
+		// Одна запись доп. данных сразу за заголовком, её содержимое — за ней
+		le.PutUint32(block[32:], vtexHeaderSize-32)
+		le.PutUint32(block[36:], 1)
+		sizes := make([]byte, 12+4*len(s.compressed))
+		le.PutUint32(sizes[8:], uint32(len(s.compressed)))
+		for i, size := range s.compressed {
+			le.PutUint32(sizes[12+4*i:], uint32(size))
+		}
+		extra := make([]byte, 12)
+		le.PutUint32(extra[0:], extraCompressedMipSize)
+		le.PutUint32(extra[4:], 8) // содержимое начинается сразу после записи
+		le.PutUint32(extra[8:], uint32(len(sizes)))
+		block = append(append(block, extra...), sizes...)
+	}
+
+	const headerSize, tableSize = 16, 12
+	data := make([]byte, headerSize+tableSize)
+	le.PutUint16(data[4:], 12)
+	le.PutUint32(data[8:], headerSize-8) // таблица блоков сразу после заголовка
+	le.PutUint32(data[12:], 1)
+	copy(data[16:], "DATA")
+	le.PutUint32(data[20:], headerSize+tableSize-20)
+	le.PutUint32(data[24:], uint32(len(block)))
+	data = append(append(data, block...), s.mips...)
+	le.PutUint32(data[0:], uint32(len(data)))
+	return data
+}
+
+// pixel возвращает цвет пикселя NRGBA-картинки
+func pixel(img image.Image, x, y int) [4]uint8 {
+	n := img.(*image.NRGBA)
+	at := n.PixOffset(x, y)
+	return [4]uint8(n.Pix[at : at+4])
+}
+
+// checkPixels сравнивает первые пиксели картинки шириной 4 (по строкам) с want
+func checkPixels(t *testing.T, img image.Image, want [][4]uint8) {
+	t.Helper()
+	for x, w := range want {
+		if got := pixel(img, x%4, x/4); got != w {
+			t.Errorf("pixel %d = %v, want %v", x, got, w)
+		}
+	}
+}
+
+// bitWriter собирает блок BC7, начиная с младшего бита
+type bitWriter struct {
+	block [16]byte
+	pos   int
+}
+
+func (w *bitWriter) write(n, v int) {
+	for i := 0; i < n; i++ {
+		if v>>i&1 != 0 {
+			w.block[w.pos/8] |= 1 << (w.pos % 8)
+		}
+		w.pos++
+	}
+}
+
+func TestDecodeDXT1(t *testing.T) {
+	// Красный и синий в RGB565, индексы пикселей строки — 0, 1, 2, 3
+	block := []byte{0x00, 0xF8, 0x1F, 0x00, 0xE4, 0xE4, 0xE4, 0xE4}
+	img, err := Decode(buildVTex(vtexSpec{format: FormatDXT1, width: 4, height: 4, mips: block}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// color0 > color1: промежуточные цвета — 2/3 и 1/3 пути от красного к синему
+	checkPixels(t, img, [][4]uint8{{255, 0, 0, 255}, {0, 0, 255, 255}, {170, 0, 85, 255}, {85, 0, 170, 255}})
+
+	// color0 <= color1: третий цвет — середина, четвёртый — прозрачный чёрный
+	block = []byte{0x1F, 0x00, 0x00, 0xF8, 0xE4, 0xE4, 0xE4, 0xE4}
+	img, err = Decode(buildVTex(vtexSpec{format: FormatDXT1, width: 4, height: 4, mips: block}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPixels(t, img, [][4]uint8{{0, 0, 255, 255}, {255, 0, 0, 255}, {127, 0, 127, 255}, {0, 0, 0, 0}})
+}
+
+func TestDecodeDXT1PartialBlock(t *testing.T) {
+	// Картинка 2×2 занимает левый верхний угол блока 4×4
+	block := []byte{0x00, 0xF8, 0x1F, 0x00, 0xE4, 0xE4, 0xE4, 0xE4}
+	img, err := Decode(buildVTex(vtexSpec{format: FormatDXT1, width: 2, height: 2, mips: block}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 2 {
+		t.Fatalf("bounds = %v, want 2x2", b)
+	}
+	if got := pixel(img, 1, 1); got != [4]uint8{0, 0, 255, 255} {
+		t.Errorf("pixel (1,1) = %v, want blue", got)
+	}
+}
+
+func TestDecodeDXT5(t *testing.T) {
+	// Альфа 255 → 0 с восемью уровнями, пиксель i берёт уровень i%8.
+	// Цвет — зелёный в обоих опорных цветах.
+	var bits uint64
+	for i := 0; i < 16; i++ {
+		bits |= uint64(i%8) << (3 * i)
+	}
+	block := []byte{255, 0}
+	block = append(block, binary.LittleEndian.AppendUint64(nil, bits)[:6]...)
+	block = append(block, 0xE0, 0x07, 0xE0, 0x07, 0, 0, 0, 0)
+
+	img, err := Decode(buildVTex(vtexSpec{format: FormatDXT5, width: 4, height: 4, mips: block}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a0 > a1: промежуточные уровни (7-i)/7·a0 + i/7·a1
+	alphas := []uint8{255, 0, 218, 182, 145, 109, 72, 36, 255, 0, 218, 182, 145, 109, 72, 36}
+	var want [][4]uint8
+	for _, a := range alphas {
+		want = append(want, [4]uint8{0, 255, 0, a})
+	}
+	checkPixels(t, img, want)
+}
+
+func TestDecodeBC7(t *testing.T) {
+	// Режим 6: одно подмножество, RGBA 7 бит + p-бит у каждой точки, индексы 4 бита
+	var w bitWriter
+	w.write(7, 1<<6) // номер режима унарным кодом
+	for _, pair := range [][2]int{{100, 10}, {20, 120}, {0, 60}, {127, 64}} {
+		w.write(7, pair[0])
+		w.write(7, pair[1])
+	}
+	w.write(1, 1) // p-бит первой точки
+	w.write(1, 0) // p-бит второй точки
+	w.write(3, 0) // опорный пиксель: индекс на бит короче
+	w.write(4, 15)
+	w.write(4, 8)
+	for i := 3; i < 16; i++ {
+		w.write(4, 0)
+	}
+	if w.pos != 128 {
+		t.Fatalf("block is %d bits", w.pos)
+	}
+
+	img, err := Decode(buildVTex(vtexSpec{format: FormatBC7, width: 4, height: 4, mips: w.block[:]}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Точки: (201, 41, 1, 255) и (20, 240, 120, 128). Вес индекса 8 — 34/64:
+	// ((64-34)·e0 + 34·e1 + 32) >> 6
+	checkPixels(t, img, [][4]uint8{{201, 41, 1, 255}, {20, 240, 120, 128}, {105, 147, 64, 188}, {201, 41, 1, 255}})
+}
+
+func TestDecodeBC7ReservedMode(t *testing.T) {
+	img, err := Decode(buildVTex(vtexSpec{format: FormatBC7, width: 4, height: 4, mips: make([]byte, 16)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPixels(t, img, [][4]uint8{{0, 0, 0, 0}})
+}
+
+func TestDecodeCompressedMip(t *testing.T) {
+	pixels := make([]byte, 8*8*4)
+	for i := range pixels {
+		pixels[i] = byte(i % 8)
+	}
+	compressed := make([]byte, lz4.CompressBlockBound(len(pixels)))
+	n, err := lz4.CompressBlock(pixels, compressed, nil)
+	if err != nil || n == 0 {
+		t.Fatalf("compress: %d, %v", n, err)
+	}
+	img, err := Decode(buildVTex(vtexSpec{
+		format: FormatRGBA8888, width: 8, height: 8,
+		mips: compressed[:n], compressed: []int{n},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(img, 1, 0); got != [4]uint8{4, 5, 6, 7} {
+		t.Errorf("pixel (1,0) = %v, want [4 5 6 7]", got)
+	}
+}
+
+func TestReadHeaderRejectsHugeTextures(t *testing.T) {
+	tests := []struct {
+		name string
+		spec vtexSpec
+	}{
+		{"wide", vtexSpec{format: FormatRGBA8888, width: 65535, height: 1}},
+		{"huge", vtexSpec{format: FormatRGBA8888, width: 65535, height: 65535}},
+		{"cube", vtexSpec{format: FormatBC7, width: 16384, height: 16384, flags: flagCube}},
+		{"deep", vtexSpec{format: FormatDXT1, width: 4096, height: 4096, depth: 65535}},
+		{"one face over the texel limit", vtexSpec{format: FormatBC7, width: 8192, height: 4096}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ReadHeader(buildVTex(tt.spec))
+			if !errors.Is(err, resource.ErrCorrupt) {
+				t.Fatalf("ReadHeader error = %v, want %v", err, resource.ErrCorrupt)
+			}
+		})
+	}
+
+	for _, spec := range []vtexSpec{
+		{format: FormatBC7, width: 4096, height: 4096},
+		{format: FormatBC7, width: 4096, height: 4096, flags: flagCube},
+		{format: FormatDXT1, width: 16384, height: 1024},
+	} {
+		h, _, err := ReadHeader(buildVTex(spec))
+		if err != nil || h.Width != spec.width {
+			t.Errorf("ReadHeader(%dx%d) = %+v, %v", spec.width, spec.height, h, err)
+		}
+	}
+}
+
+func TestDecodeRejectsBadMips(t *testing.T) {
+	tests := []struct {
+		name string
+		spec vtexSpec
+	}{
+		{"truncated", vtexSpec{format: FormatDXT1, width: 8, height: 8, mips: make([]byte, 16)}},
+		{"bad lz4", vtexSpec{format: FormatRGBA8888, width: 8, height: 8, mips: []byte{0xFF, 1, 2, 3}, compressed: []int{4}}},
+		{"lz4 too short", vtexSpec{format: FormatRGBA8888, width: 8, height: 8, mips: []byte{0x10, 1}, compressed: []int{2}}},
+		{"lz4 bad offset", vtexSpec{format: FormatRGBA8888, width: 8, height: 8, mips: []byte{0x10, 1, 5, 0, 0}, compressed: []int{5}}},
+		// 64 МиБ пикселей из восьми байт: буфер под такой мип не выделяется
+		{"lz4 bomb", vtexSpec{format: FormatRGBA8888, width: 4096, height: 4096, mips: []byte{0x1F, 0, 1, 0, 255, 255, 255, 0}, compressed: []int{8}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(buildVTex(tt.spec)); !errors.Is(err, resource.ErrCorrupt) {
+				t.Fatalf("Decode error = %v, want %v", err, resource.ErrCorrupt)
+			}
+		})
+	}
+}
+
+func TestDecodeCubeFirstFace(t *testing.T) {
+	// Шесть граней 4×4 подряд, грань i залита байтом i
+	pixels := make([]byte, 6*4*4*4)
+	for i := range pixels {
+		pixels[i] = byte(i / 64)
+	}
+	compressed := make([]byte, lz4.CompressBlockBound(len(pixels)))
+	n, err := lz4.CompressBlock(pixels, compressed, nil)
+	if err != nil || n == 0 {
+		t.Fatalf("compress: %d, %v", n, err)
+	}
+	img, err := Decode(buildVTex(vtexSpec{
+		format: FormatRGBA8888, width: 4, height: 4, flags: flagCube,
+		mips: compressed[:n], compressed: []int{n},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(img, 3, 3); got != [4]uint8{} {
+		t.Errorf("pixel (3,3) = %v, want the first face", got)
+	}
+}
+
+func TestUncompressLZ4Prefix(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 64<<10)
+	for i := range data {
+		// Повторы разной длины, чтобы в блоке были и литералы, и длинные совпадения
+		if i > 300 && rng.Intn(4) > 0 {
+			data[i] = data[i-1-rng.Intn(300)]
+		} else {
+			data[i] = byte(rng.Intn(256))
+		}
+	}
+	compressed := make([]byte, lz4.CompressBlockBound(len(data)))
+	n, err := lz4.CompressBlock(data, compressed, nil)
+	if err != nil || n == 0 {
+		t.Fatalf("compress: %d, %v", n, err)
+	}
+
+	for _, size := range []int{len(data), len(data) / 3, 1} {
+		out := make([]byte, size)
+		got, err := uncompressLZ4Prefix(compressed[:n], out)
+		if err != nil || got != size || !bytes.Equal(out, data[:size]) {
+			t.Errorf("uncompressLZ4Prefix(%d) = %d, %v; data equal %v", size, got, err, bytes.Equal(out, data[:size]))
+		}
+	}
+
+	// Повреждённый блок: обрезанные литералы, нулевое смещение, смещение до начала
+	for _, src := range [][]byte{{0xF0, 20}, {0x10, 1, 0, 0}, {0x10, 1, 2, 0}} {
+		if _, err := uncompressLZ4Prefix(src, make([]byte, 64)); err == nil {
+			t.Errorf("uncompressLZ4Prefix(%v) succeeded", src)
+		}
+	}
+}
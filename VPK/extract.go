@@ -0,0 +1,104 @@
+package vpk
+
+import (
+	"DeadlockHelper/internal/fsutil"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Match сообщает, подходит ли путь файла в VPK под шаблон. Шаблон сравнивается
+// по частям пути, как в path.Match, а часть «**» заменяет любое число каталогов.
+// Шаблон, совпавший с каталогом, выбирает его целиком: «sounds/ui» и «sounds/u*»
+// подходят для «sounds/ui/click.vsnd_c».
+func Match(pattern, name string) bool {
+	pattern = strings.TrimSuffix(NormalizePath(pattern), "/")
+	name = NormalizePath(name)
+	if pattern == "" || pattern == "**" {
+		return true
+	}
+	return matchParts(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return true // остаток name — содержимое совпавшего каталога
+}
+
+// Select возвращает отсортированные пути файлов, подходящих хотя бы под один из
+// шаблонов (см. Match). Без шаблонов возвращаются все файлы.
+func (a *Archive) Select(patterns []string) []string {
+	if len(patterns) == 0 {
+		return a.Paths()
+	}
+	var paths []string
+	for _, p := range a.Paths() {
+		for _, pattern := range patterns {
+			if Match(pattern, p) {
+				paths = append(paths, p)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// Extract записывает файлы paths в папку dstDir, сохраняя пути внутри VPK.
+// Существующие файлы перезаписываются. Пути, выходящие за пределы dstDir или
+// недопустимые в Windows (C:foo, NUL), считаются повреждением архива.
+func (a *Archive) Extract(dstDir string, paths []string) error {
+	for _, p := range paths {
+		e, ok := a.Find(p)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrNotFound, p)
+		}
+		outPath, err := fsutil.SafeJoin(dstDir, e.Path)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrCorrupt, err)
+		}
+		if err := a.extractEntry(e, outPath); err != nil {
+			return fmt.Errorf("%s: %w", e.Path, err)
+		}
+	}
+	return nil
+}
+
+func (a *Archive) extractEntry(e Entry, outPath string) error {
+	rc, err := a.OpenEntry(e)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
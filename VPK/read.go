@@ -0,0 +1,72 @@
+package vpk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// OpenEntry возвращает содержимое файла: сначала preload из дерева, затем данные
+// из _dir.vpk или из куска архива
+func (a *Archive) OpenEntry(e Entry) (io.ReadCloser, error) {
+	preload := bytes.NewReader(e.Preload)
+	if e.Length == 0 {
+		return io.NopCloser(preload), nil
+	}
+
+	chunkPath, err := a.ChunkPath(e.ArchiveIndex)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(chunkPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.Path, err)
+	}
+
+	offset := int64(e.Offset)
+	if e.ArchiveIndex == DirArchive {
+		offset += a.DataOffset()
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if offset+int64(e.Length) > info.Size() {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s points past the end of %s", ErrCorrupt, e.Path, info.Name())
+	}
+
+	data := io.NewSectionReader(f, offset, int64(e.Length))
+	return &entryReader{Reader: io.MultiReader(preload, data), f: f}, nil
+}
+
+type entryReader struct {
+	io.Reader
+	f *os.File
+}
+
+func (r *entryReader) Close() error {
+	return r.f.Close()
+}
+
+// ReadFile читает файл по пути целиком
+func (a *Archive) ReadFile(path string) ([]byte, error) {
+	e, ok := a.Find(path)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	rc, err := a.OpenEntry(e)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data := make([]byte, 0, e.Size())
+	buf := bytes.NewBuffer(data)
+	if _, err := io.Copy(buf, rc); err != nil {
+		return nil, fmt.Errorf("%s: %w", e.Path, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+//go:build ignore
+
+// gen собирает тестовые VPK вручную, не через vpk.Create, чтобы тесты чтения не
+// зависели от записи. Запуск: go run gen.go в папке testdata.
+//
+//	pak01_dir.vpk + pak01_000.vpk  v2 с куском, preload и полным футером MD5
+//	single.vpk                     v1 одним файлом, все данные после дерева
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"hash/crc32"
+	"log"
+	"os"
+)
+
+type file struct {
+	ext, dir, name string
+	data           string
+	preload        int    // сколько байт начала лежит в дереве
+	archive        uint16 // 0x7FFF — в самом _dir.vpk
+}
+
+var le = binary.LittleEndian
+
+// build собирает дерево и данные: content[archive] — данные каждого куска
+func build(files []file) ([]byte, map[uint16][]byte) {
+	var tree bytes.Buffer
+	content := make(map[uint16][]byte)
+	str := func(s string) { tree.WriteString(s); tree.WriteByte(0) }
+	for i := 0; i < len(files); {
+		ext := files[i].ext
+		str(ext)
+		for i < len(files) && files[i].ext == ext {
+			dir := files[i].dir
+			str(dir)
+			for ; i < len(files) && files[i].ext == ext && files[i].dir == dir; i++ {
+				f := files[i]
+				str(f.name)
+				rest := f.data[f.preload:]
+				var entry [18]byte
+				le.PutUint32(entry[0:], crc32.ChecksumIEEE([]byte(f.data)))
+				le.PutUint16(entry[4:], uint16(f.preload))
+				le.PutUint16(entry[6:], f.archive)
+				le.PutUint32(entry[8:], uint32(len(content[f.archive])))
+				le.PutUint32(entry[12:], uint32(len(rest)))
+				le.PutUint16(entry[16:], 0xFFFF)
+				tree.Write(entry[:])
+				tree.WriteString(f.data[:f.preload])
+				content[f.archive] = append(content[f.archive], rest...)
+			}
+			str("")
+		}
+		str("")
+	}
+	str("")
+	return tree.Bytes(), content
+}
+
+func chunked() {
+	tree, content := build([]file{
+		{ext: " ", dir: "scripts", name: "readme", data: "data in the dir file", archive: 0x7FFF},
+		{ext: "cfg", dir: " ", name: "root", data: "root config in chunk 000", archive: 0},
+		{ext: "txt", dir: "materials/test", name: "hello", data: "hello from chunk 000", preload: 5, archive: 0},
+	})
+	chunk := content[0]
+	dirData := content[0x7FFF]
+
+	chunkSum := md5.Sum(chunk)
+	var section bytes.Buffer
+	binary.Write(&section, le, [3]uint32{0, 0, uint32(len(chunk))})
+	section.Write(chunkSum[:])
+
+	var out bytes.Buffer
+	binary.Write(&out, le, [7]uint32{0x55AA1234, 2, uint32(len(tree)), uint32(len(dirData)), uint32(section.Len()), 48, 0})
+	out.Write(tree)
+	out.Write(dirData)
+	out.Write(section.Bytes())
+	treeSum, sectionSum := md5.Sum(tree), md5.Sum(section.Bytes())
+	out.Write(treeSum[:])
+	out.Write(sectionSum[:])
+	wholeSum := md5.Sum(out.Bytes())
+	out.Write(wholeSum[:])
+
+	write("pak01_dir.vpk", out.Bytes())
+	write("pak01_000.vpk", chunk)
+}
+
+func single() {
+	tree, content := build([]file{
+		{ext: "vmdl_c", dir: "models/heroes", name: "hero", data: "model data", archive: 0x7FFF},
+		{ext: "vtex_c", dir: "panorama/images", name: "Icon", data: "texture data", preload: 4, archive: 0x7FFF},
+	})
+	var out bytes.Buffer
+	binary.Write(&out, le, [3]uint32{0x55AA1234, 1, uint32(len(tree))})
+	out.Write(tree)
+	out.Write(content[0x7FFF])
+	write("single.vpk", out.Bytes())
+}
+
+func write(name string, data []byte) {
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	chunked()
+	single()
+}
@@ -0,0 +1,190 @@
+package vpk
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// maxProblems — после стольких найденных ошибок проверка останавливается
+const maxProblems = 20
+
+// Verify открывает VPK и проверяет его целиком, см. Archive.Verify
+func Verify(path string) error {
+	a, err := Open(path)
+	if err != nil {
+		return err
+	}
+	return a.Verify()
+}
+
+// Verify проверяет, что игра сможет прочитать архив: данные каждой записи лежат
+// в пределах своего файла, CRC32 содержимого совпадает с деревом, а в v2 совпадают
+// MD5 участков кусков, дерева и всего _dir.vpk. Возвращает nil для целого архива
+// или ошибку со списком проблем, каждая из которых оборачивает ErrCorrupt.
+func (a *Archive) Verify() error {
+	var problems []error
+	report := func(format string, args ...any) bool {
+		problems = append(problems, fmt.Errorf("%w: "+format, append([]any{ErrCorrupt}, args...)...))
+		return len(problems) < maxProblems
+	}
+
+	sizes := make(map[uint16]int64)
+	chunkSize := func(index uint16) (int64, error) {
+		if size, ok := sizes[index]; ok {
+			return size, nil
+		}
+		chunkPath, err := a.ChunkPath(index)
+		if err != nil {
+			return 0, err
+		}
+		info, err := os.Stat(chunkPath)
+		if err != nil {
+			return 0, err
+		}
+		size := info.Size()
+		if index == DirArchive {
+			size = int64(a.Header.FileDataSize)
+			if a.Header.Version == 1 {
+				size = info.Size() - a.DataOffset()
+			}
+		}
+		sizes[index] = size
+		return size, nil
+	}
+
+	for _, e := range a.Entries {
+		if e.Length > 0 {
+			size, err := chunkSize(e.ArchiveIndex)
+			if err != nil {
+				if !report("%s: %v", e.Path, err) {
+					break
+				}
+				continue
+			}
+			if int64(e.Offset)+int64(e.Length) > size {
+				if !report("%s: data at %d+%d is outside the archive (%d bytes)", e.Path, e.Offset, e.Length, size) {
+					break
+				}
+				continue
+			}
+		}
+		if err := a.checkCRC(e); err != nil {
+			if !report("%s: %v", e.Path, err) {
+				break
+			}
+		}
+	}
+
+	if len(problems) < maxProblems {
+		for _, m := range a.ArchiveMD5s {
+			if err := a.checkArchiveMD5(m); err != nil && !report("archive %d at %d: %v", m.ArchiveIndex, m.Offset, err) {
+				break
+			}
+		}
+	}
+	if len(problems) < maxProblems && a.OtherMD5 != nil {
+		if err := a.checkOtherMD5(); err != nil {
+			report("%v", err)
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// checkCRC читает запись целиком и сравнивает её CRC32 с деревом
+func (a *Archive) checkCRC(e Entry) error {
+	rc, err := a.OpenEntry(e)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	h := crc32.NewIEEE()
+	n, err := io.Copy(h, rc)
+	if err != nil {
+		return err
+	}
+	if n != e.Size() {
+		return fmt.Errorf("truncated: read %d of %d bytes", n, e.Size())
+	}
+	if sum := h.Sum32(); sum != e.CRC {
+		return fmt.Errorf("crc mismatch: got %08x, want %08x", sum, e.CRC)
+	}
+	return nil
+}
+
+// checkArchiveMD5 сверяет MD5 участка куска архива с секцией футера
+func (a *Archive) checkArchiveMD5(m ArchiveMD5) error {
+	index := uint16(m.ArchiveIndex)
+	chunkPath, err := a.ChunkPath(index)
+	if err != nil {
+		return err
+	}
+	offset := int64(m.Offset)
+	if index == DirArchive {
+		offset += a.DataOffset()
+	}
+	sum, err := md5Range(chunkPath, offset, int64(m.Length))
+	if err != nil {
+		return err
+	}
+	if sum != m.MD5 {
+		return fmt.Errorf("md5 mismatch")
+	}
+	return nil
+}
+
+// checkOtherMD5 сверяет MD5 дерева, секции MD5 кусков и всего _dir.vpk до них
+func (a *Archive) checkOtherMD5() error {
+	h := a.Header
+	treeSum, err := md5Range(a.path, h.Size(), int64(h.TreeSize))
+	if err != nil {
+		return err
+	}
+	if treeSum != a.OtherMD5.Tree {
+		return fmt.Errorf("tree md5 mismatch")
+	}
+
+	md5Start := a.DataOffset() + int64(h.FileDataSize)
+	sectionSum, err := md5Range(a.path, md5Start, int64(h.ArchiveMD5Size))
+	if err != nil {
+		return err
+	}
+	if sectionSum != a.OtherMD5.ArchiveMD5 {
+		return fmt.Errorf("archive md5 section md5 mismatch")
+	}
+
+	// Контрольная сумма всего файла покрывает всё до неё самой, включая две суммы выше
+	wholeSum, err := md5Range(a.path, 0, md5Start+int64(h.ArchiveMD5Size)+32)
+	if err != nil {
+		return err
+	}
+	if wholeSum != a.OtherMD5.WholeFile {
+		return fmt.Errorf("whole file md5 mismatch")
+	}
+	return nil
+}
+
+// md5Range считает MD5 участка файла
+func md5Range(path string, offset, length int64) ([16]byte, error) {
+	var sum [16]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	n, err := io.Copy(h, io.NewSectionReader(f, offset, length))
+	if err != nil {
+		return sum, err
+	}
+	if n != length {
+		return sum, fmt.Errorf("range %d+%d is outside the file", offset, length)
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,429 @@
+package vpk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Формат Valve Pak (VPK) версий 1 и 2:
+//
+//	заголовок      сигнатура, версия, размер дерева (и размеры секций футера в v2)
+//	дерево         расширение → путь → имя файла → запись, каждая строка оканчивается нулём
+//	данные         файлы, лежащие в самом _dir.vpk (индекс архива 0x7FFF)
+//	футер (v2)     MD5 кусков архивов, MD5 дерева и всего файла, подпись
+//
+// Остальные данные лежат в файлах-кусках pakNN_000.vpk, pakNN_001.vpk, ...
+
+// Signature — первые четыре байта любого VPK
+const Signature = 0x55AA1234
+
+// DirArchive — индекс архива у записей, данные которых лежат в самом _dir.vpk
+const DirArchive = 0x7FFF
+
+const (
+	headerSizeV1    = 12
+	headerSizeV2    = 28
+	entryTerminator = 0xFFFF
+	entrySize       = 18 // CRC, preload, индекс архива, смещение, длина, терминатор
+	archiveMD5Size  = 28
+	otherMD5Size    = 48
+)
+
+var (
+	ErrNotVPK             = errors.New("not a vpk file")
+	ErrUnsupportedVersion = errors.New("unsupported vpk version")
+	ErrCorrupt            = errors.New("corrupt vpk")
+	ErrNotFound           = errors.New("file not found in vpk")
+)
+
+// Header — заголовок VPK. Поля секций футера заполнены только в версии 2.
+type Header struct {
+	Version        uint32
+	TreeSize       uint32
+	FileDataSize   uint32 // размер данных в самом _dir.vpk после дерева
+	ArchiveMD5Size uint32
+	OtherMD5Size   uint32
+	SignatureSize  uint32
+}
+
+// Size возвращает размер заголовка в байтах
+func (h Header) Size() int64 {
+	if h.Version == 1 {
+		return headerSizeV1
+	}
+	return headerSizeV2
+}
+
+// Entry — файл внутри VPK
+type Entry struct {
+	Path         string // полный путь в нижнем регистре через «/», например materials/hud/icon.vtex_c
+	CRC          uint32 // CRC32 полного содержимого файла
+	Preload      []byte // начало файла, хранящееся прямо в дереве
+	ArchiveIndex uint16 // номер куска pakNN_NNN.vpk или DirArchive
+	Offset       uint32 // смещение данных в куске (для DirArchive — от конца дерева)
+	Length       uint32 // длина данных в куске без preload
+}
+
+// Size возвращает полный размер файла
+func (e Entry) Size() int64 {
+	return int64(len(e.Preload)) + int64(e.Length)
+}
+
+// ArchiveMD5 — MD5 участка одного куска архива (секция футера v2)
+type ArchiveMD5 struct {
+	ArchiveIndex uint32
+	Offset       uint32
+	Length       uint32
+	MD5          [16]byte
+}
+
+// OtherMD5 — контрольные суммы дерева, секции ArchiveMD5 и всего _dir.vpk до них
+type OtherMD5 struct {
+	Tree       [16]byte
+	ArchiveMD5 [16]byte
+	WholeFile  [16]byte
+}
+
+// SignatureSection — открытый ключ и подпись архива, если он подписан
+type SignatureSection struct {
+	PublicKey []byte
+	Signature []byte
+}
+
+// Archive — открытый VPK. Читается только заголовок, дерево и футер, данные файлов
+// читаются по запросу из _dir.vpk или из кусков.
+type Archive struct {
+	Header      Header
+	Entries     []Entry // в порядке следования в дереве
+	ArchiveMD5s []ArchiveMD5
+	OtherMD5    *OtherMD5
+	Signature   *SignatureSection
+
+	path  string
+	index map[string]int
+}
+
+// Open читает заголовок, дерево и футер VPK. path — путь к pakNN_dir.vpk или к
+// одиночному VPK без кусков.
+func Open(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := parse(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	a.path = path
+	return a, nil
+}
+
+// parse разбирает VPK размером size
+func parse(r io.ReaderAt, size int64) (*Archive, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	treeEnd := h.Size() + int64(h.TreeSize)
+	if treeEnd > size {
+		return nil, fmt.Errorf("%w: tree size %d exceeds file size %d", ErrCorrupt, h.TreeSize, size)
+	}
+
+	a := &Archive{Header: h, index: make(map[string]int)}
+	tree := make([]byte, h.TreeSize)
+	if _, err := r.ReadAt(tree, h.Size()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if err := a.parseTree(tree); err != nil {
+		return nil, err
+	}
+
+	if h.Version == 2 {
+		footerSize := int64(h.ArchiveMD5Size) + int64(h.OtherMD5Size) + int64(h.SignatureSize)
+		footerStart := treeEnd + int64(h.FileDataSize)
+		if footerStart+footerSize > size {
+			return nil, fmt.Errorf("%w: footer exceeds file size", ErrCorrupt)
+		}
+		footer := make([]byte, footerSize)
+		if _, err := r.ReadAt(footer, footerStart); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		if err := a.parseFooter(footer); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func readHeader(r io.ReaderAt) (Header, error) {
+	var buf [headerSizeV2]byte
+	n, err := r.ReadAt(buf[:], 0)
+	if n < headerSizeV1 {
+		if err == nil || err == io.EOF {
+			return Header{}, ErrNotVPK
+		}
+		return Header{}, err
+	}
+	le := binary.LittleEndian
+	if le.Uint32(buf[0:]) != Signature {
+		return Header{}, ErrNotVPK
+	}
+
+	h := Header{Version: le.Uint32(buf[4:]), TreeSize: le.Uint32(buf[8:])}
+	switch h.Version {
+	case 1:
+	case 2:
+		if n < headerSizeV2 {
+			return Header{}, fmt.Errorf("%w: truncated header", ErrCorrupt)
+		}
+		h.FileDataSize = le.Uint32(buf[12:])
+		h.ArchiveMD5Size = le.Uint32(buf[16:])
+		h.OtherMD5Size = le.Uint32(buf[20:])
+		h.SignatureSize = le.Uint32(buf[24:])
+	default:
+		return Header{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, h.Version)
+	}
+	return h, nil
+}
+
+// parseTree разбирает дерево: расширения, в каждом из них пути, в каждом пути имена файлов.
+// Пробел вместо пути означает корень, пробел вместо расширения — файл без расширения.
+func (a *Archive) parseTree(tree []byte) error {
+	r := &treeReader{buf: tree}
+	for {
+		ext, err := r.str()
+		if err != nil || ext == "" {
+			return err
+		}
+		for {
+			dir, err := r.str()
+			if err != nil {
+				return err
+			}
+			if dir == "" {
+				break
+			}
+			for {
+				name, err := r.str()
+				if err != nil {
+					return err
+				}
+				if name == "" {
+					break
+				}
+				e, err := r.entry()
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				e.Path = joinPath(dir, name, ext)
+				a.index[e.Path] = len(a.Entries)
+				a.Entries = append(a.Entries, e)
+			}
+		}
+	}
+}
+
+// treeReader читает строки и записи из буфера дерева, не выходя за его границы
+type treeReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *treeReader) str() (string, error) {
+	end := bytes.IndexByte(r.buf[r.pos:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("%w: unterminated string in tree", ErrCorrupt)
+	}
+	s := string(r.buf[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s, nil
+}
+
+func (r *treeReader) entry() (Entry, error) {
+	if len(r.buf)-r.pos < entrySize {
+		return Entry{}, fmt.Errorf("%w: truncated entry", ErrCorrupt)
+	}
+	le := binary.LittleEndian
+	b := r.buf[r.pos:]
+	e := Entry{
+		CRC:          le.Uint32(b[0:]),
+		ArchiveIndex: le.Uint16(b[6:]),
+		Offset:       le.Uint32(b[8:]),
+		Length:       le.Uint32(b[12:]),
+	}
+	preload := int(le.Uint16(b[4:]))
+	if le.Uint16(b[16:]) != entryTerminator {
+		return Entry{}, fmt.Errorf("%w: bad entry terminator", ErrCorrupt)
+	}
+	r.pos += entrySize
+	if len(r.buf)-r.pos < preload {
+		return Entry{}, fmt.Errorf("%w: truncated preload data", ErrCorrupt)
+	}
+	if preload > 0 {
+		e.Preload = r.buf[r.pos : r.pos+preload]
+		r.pos += preload
+	}
+	return e, nil
+}
+
+func (a *Archive) parseFooter(footer []byte) error {
+	le := binary.LittleEndian
+	h := a.Header
+
+	md5s := footer[:h.ArchiveMD5Size]
+	if len(md5s)%archiveMD5Size != 0 {
+		return fmt.Errorf("%w: bad archive md5 section size %d", ErrCorrupt, len(md5s))
+	}
+	for b := md5s; len(b) > 0; b = b[archiveMD5Size:] {
+		m := ArchiveMD5{
+			ArchiveIndex: le.Uint32(b[0:]),
+			Offset:       le.Uint32(b[4:]),
+			Length:       le.Uint32(b[8:]),
+		}
+		copy(m.MD5[:], b[12:28])
+		a.ArchiveMD5s = append(a.ArchiveMD5s, m)
+	}
+
+	other := footer[h.ArchiveMD5Size : h.ArchiveMD5Size+h.OtherMD5Size]
+	switch len(other) {
+	case 0:
+	case otherMD5Size:
+		a.OtherMD5 = &OtherMD5{}
+		copy(a.OtherMD5.Tree[:], other[0:16])
+		copy(a.OtherMD5.ArchiveMD5[:], other[16:32])
+		copy(a.OtherMD5.WholeFile[:], other[32:48])
+	default:
+		return fmt.Errorf("%w: bad other md5 section size %d", ErrCorrupt, len(other))
+	}
+
+	sig := footer[h.ArchiveMD5Size+h.OtherMD5Size:]
+	if len(sig) == 0 {
+		return nil
+	}
+	keySize, keyErr := sectionLen(sig, 0)
+	sigSize, sigErr := sectionLen(sig, 4+keySize)
+	if keyErr != nil || sigErr != nil || 8+keySize+sigSize != len(sig) {
+		return fmt.Errorf("%w: bad signature section", ErrCorrupt)
+	}
+	a.Signature = &SignatureSection{
+		PublicKey: sig[4 : 4+keySize],
+		Signature: sig[8+keySize : 8+keySize+sigSize],
+	}
+	return nil
+}
+
+// sectionLen читает длину из четырёх байт по смещению off и проверяет, что столько
+// байт после неё есть в буфере
+func sectionLen(b []byte, off int) (int, error) {
+	if off < 0 || off+4 > len(b) {
+		return 0, ErrCorrupt
+	}
+	n := int(binary.LittleEndian.Uint32(b[off:]))
+	if n > len(b)-off-4 {
+		return 0, ErrCorrupt
+	}
+	return n, nil
+}
+
+// joinPath собирает полный путь файла из строк дерева
+func joinPath(dir, name, ext string) string {
+	full := name
+	if ext != " " {
+		full += "." + ext
+	}
+	if dir != " " {
+		full = strings.TrimSuffix(dir, "/") + "/" + full
+	}
+	return NormalizePath(full)
+}
+
+// NormalizePath приводит путь к виду, в котором он хранится в Entry.Path
+func NormalizePath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	return strings.ToLower(strings.TrimPrefix(p, "/"))
+}
+
+// Path возвращает путь к открытому файлу VPK
+func (a *Archive) Path() string {
+	return a.path
+}
+
+// DataOffset возвращает смещение начала данных внутри _dir.vpk
+func (a *Archive) DataOffset() int64 {
+	return a.Header.Size() + int64(a.Header.TreeSize)
+}
+
+// Find ищет запись по пути. Регистр и вид разделителей не важны.
+func (a *Archive) Find(path string) (Entry, bool) {
+	i, ok := a.index[NormalizePath(path)]
+	if !ok {
+		return Entry{}, false
+	}
+	return a.Entries[i], true
+}
+
+// Paths возвращает отсортированный список путей всех файлов
+func (a *Archive) Paths() []string {
+	paths := make([]string, 0, len(a.Entries))
+	for _, e := range a.Entries {
+		paths = append(paths, e.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ChunkPath возвращает путь к куску архива с номером index: pak01_dir.vpk → pak01_003.vpk
+func (a *Archive) ChunkPath(index uint16) (string, error) {
+	if index == DirArchive {
+		return a.path, nil
+	}
+	dir, name := filepath.Split(a.path)
+	lower := strings.ToLower(name)
+	if !strings.HasSuffix(lower, "_dir.vpk") {
+		return "", fmt.Errorf("%w: %s has no chunk files", ErrCorrupt, name)
+	}
+	prefix := name[:len(name)-len("dir.vpk")]
+	return filepath.Join(dir, fmt.Sprintf("%s%03d.vpk", prefix, index)), nil
+}
+
+// Chunks возвращает номера кусков, на которые ссылаются записи, по возрастанию
+func (a *Archive) Chunks() []uint16 {
+	seen := make(map[uint16]bool)
+	var chunks []uint16
+	for _, e := range a.Entries {
+		if e.ArchiveIndex != DirArchive && e.Length > 0 && !seen[e.ArchiveIndex] {
+			seen[e.ArchiveIndex] = true
+			chunks = append(chunks, e.ArchiveIndex)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i] < chunks[j] })
+	return chunks
+}
+
+// IsVPK сообщает, начинается ли файл с сигнатуры VPK
+func IsVPK(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var sig [4]byte
+	if _, err := io.ReadFull(f, sig[:]); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint32(sig[:]) == Signature
+}
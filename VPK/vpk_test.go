@@ -0,0 +1,377 @@
+package vpk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Тестовые VPK в testdata собраны testdata/gen.go
+
+// copyTestdata копирует файлы из testdata во временную папку, чтобы их можно было портить
+func copyTestdata(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// flipByte инвертирует байт файла по смещению off (отрицательное — от конца)
+func flipByte(t *testing.T, path string, off int) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if off < 0 {
+		off += len(data)
+	}
+	data[off] ^= 0xFF
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		path    string
+		version uint32
+		files   map[string]string
+		chunks  []uint16
+	}{
+		{
+			path:    "testdata/pak01_dir.vpk",
+			version: 2,
+			files: map[string]string{
+				"scripts/readme":           "data in the dir file",
+				"root.cfg":                 "root config in chunk 000",
+				"materials/test/hello.txt": "hello from chunk 000",
+			},
+			chunks: []uint16{0},
+		},
+		{
+			path:    "testdata/single.vpk",
+			version: 1,
+			files: map[string]string{
+				"models/heroes/hero.vmdl_c":   "model data",
+				"panorama/images/icon.vtex_c": "texture data",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.path), func(t *testing.T) {
+			a, err := Open(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Header.Version != tt.version {
+				t.Errorf("version = %d, want %d", a.Header.Version, tt.version)
+			}
+			if got := a.Paths(); len(got) != len(tt.files) {
+				t.Errorf("Paths() = %v, want %d files", got, len(tt.files))
+			}
+			for p, content := range tt.files {
+				data, err := a.ReadFile(p)
+				if err != nil {
+					t.Errorf("ReadFile(%q): %v", p, err)
+					continue
+				}
+				if string(data) != content {
+					t.Errorf("ReadFile(%q) = %q, want %q", p, data, content)
+				}
+			}
+			if got := a.Chunks(); !reflect.DeepEqual(got, tt.chunks) {
+				t.Errorf("Chunks() = %v, want %v", got, tt.chunks)
+			}
+			if err := a.Verify(); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if _, err := a.ReadFile("missing.txt"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("ReadFile(missing) error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestOpenChunkedFooter(t *testing.T) {
+	a, err := Open("testdata/pak01_dir.vpk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.ArchiveMD5s) != 1 || a.OtherMD5 == nil || a.Signature != nil {
+		t.Fatalf("footer: %d archive md5s, other md5 %v, signature %v", len(a.ArchiveMD5s), a.OtherMD5 != nil, a.Signature != nil)
+	}
+	e, ok := a.Find("Materials\\Test\\HELLO.txt")
+	if !ok {
+		t.Fatal("Find is case- or separator-sensitive")
+	}
+	if string(e.Preload) != "hello" || e.ArchiveIndex != 0 {
+		t.Errorf("entry = preload %q, archive %d; want preload %q in chunk 0", e.Preload, e.ArchiveIndex, "hello")
+	}
+	chunk, err := a.ChunkPath(0)
+	if err != nil || filepath.Base(chunk) != "pak01_000.vpk" {
+		t.Errorf("ChunkPath(0) = %q, %v", chunk, err)
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, dir string)
+	}{
+		{"chunk data", func(t *testing.T, dir string) {
+			flipByte(t, filepath.Join(dir, "pak01_000.vpk"), 3)
+		}},
+		{"truncated chunk", func(t *testing.T, dir string) {
+			if err := os.Truncate(filepath.Join(dir, "pak01_000.vpk"), 10); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"missing chunk", func(t *testing.T, dir string) {
+			if err := os.Remove(filepath.Join(dir, "pak01_000.vpk")); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"dir data", func(t *testing.T, dir string) {
+			a, err := Open(filepath.Join(dir, "pak01_dir.vpk"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			flipByte(t, filepath.Join(dir, "pak01_dir.vpk"), int(a.DataOffset()))
+		}},
+		{"whole file md5", func(t *testing.T, dir string) {
+			flipByte(t, filepath.Join(dir, "pak01_dir.vpk"), -1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := copyTestdata(t, "pak01_dir.vpk", "pak01_000.vpk")
+			tt.corrupt(t, dir)
+			err := Verify(filepath.Join(dir, "pak01_dir.vpk"))
+			if !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("Verify error = %v, want %v", err, ErrCorrupt)
+			}
+		})
+	}
+
+	t.Run("single", func(t *testing.T) {
+		dir := copyTestdata(t, "single.vpk")
+		flipByte(t, filepath.Join(dir, "single.vpk"), -1)
+		if err := Verify(filepath.Join(dir, "single.vpk")); !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("Verify error = %v, want %v", err, ErrCorrupt)
+		}
+	})
+}
+
+func TestOpenRejectsBadHeaders(t *testing.T) {
+	valid, err := os.ReadFile("testdata/single.vpk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := func(off int, v uint32) []byte {
+		data := bytes.Clone(valid)
+		binary.LittleEndian.PutUint32(data[off:], v)
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrNotVPK},
+		{"zip", []byte("PK\x03\x04 not a vpk at all"), ErrNotVPK},
+		{"version 3", patch(4, 3), ErrUnsupportedVersion},
+		{"tree past end", patch(8, 1<<20), ErrCorrupt},
+		{"truncated tree", valid[:headerSizeV1+20], ErrCorrupt},
+		{"truncated v2 header", patch(4, 2)[:headerSizeV1+4], ErrCorrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pak01_dir.vpk")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Open(path); !errors.Is(err, tt.want) {
+				t.Fatalf("Open error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackDirRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"panorama/images/hud/icon.vtex_c": "icon",
+		"sounds/Hero/Shot.vsnd_c":         "shot",
+		"readme":                          "no extension",
+		"root.txt":                        "",
+		"models/big.vmdl_c":               string(bytes.Repeat([]byte("0123456789"), 10000)),
+	}
+	src := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "pak01_dir.vpk")
+	if err := PackDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(a.Entries) != len(files) {
+		t.Errorf("%d entries, want %d", len(a.Entries), len(files))
+	}
+	for name, content := range files {
+		data, err := a.ReadFile(name)
+		if err != nil {
+			t.Errorf("ReadFile(%q): %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("ReadFile(%q) = %d bytes, want %d", name, len(data), len(content))
+		}
+	}
+
+	// Пересборка из записей того же архива даёт тот же файл
+	again := filepath.Join(filepath.Dir(dst), "pak02_dir.vpk")
+	if err := Create(again, a.Sources()); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := os.ReadFile(dst)
+	got, _ := os.ReadFile(again)
+	if !bytes.Equal(got, want) {
+		t.Error("Create(Sources()) differs from the original archive")
+	}
+
+	if err := PackDir(src, dst); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("PackDir over existing file error = %v, want %v", err, fs.ErrExist)
+	}
+	if err := PackDir(t.TempDir(), filepath.Join(t.TempDir(), "empty.vpk")); err == nil {
+		t.Error("PackDir of an empty dir succeeded")
+	}
+}
+
+func TestPackDirCorruption(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file.txt"), []byte("some file data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "pak01_dir.vpk")
+	if err := PackDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flipByte(t, dst, int(a.DataOffset())+2)
+	if err := Verify(dst); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("Verify error = %v, want %v", err, ErrCorrupt)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"materials/*.vtex_c", "materials/icon.vtex_c", true},
+		{"materials/*.vtex_c", "materials/hud/icon.vtex_c", false},
+		{"materials/**", "materials/hud/icon.vtex_c", true},
+		{"**/*.vsnd_c", "sounds/hero/shot.vsnd_c", true},
+		{"Sounds/**", "sounds/hero/shot.vsnd_c", true},
+		{"sounds/**", "models/hero.vmdl_c", false},
+		{"sounds/u*", "sounds/ui/click.vsnd_c", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractRejectsUnsafePaths(t *testing.T) {
+	// Create не пишет такие пути, поэтому имя каталога подменяется в готовом файле
+	// на имя той же длины
+	tests := []struct {
+		name    string
+		dir     string
+		replace string
+	}{
+		{"backslash traversal", "abcdefghi", `a\..\..\x`},
+		{"drive letter", "abcdefghi", "c:windows"},
+		{"drive-relative", "abcdefghi", "abcdefgh:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(src, tt.dir), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(src, tt.dir, "evil.txt"), []byte("evil"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "pak01_dir.vpk")
+			if err := PackDir(src, path); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = bytes.Replace(data, []byte(tt.dir), []byte(tt.replace), 1)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dst := filepath.Join(t.TempDir(), "out")
+			if err := a.Extract(dst, a.Paths()); !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("Extract(%v) error = %v, want %v", a.Paths(), err, ErrCorrupt)
+			}
+			if leaked, _ := filepath.Glob(filepath.Join(filepath.Dir(dst), "*", "evil.txt")); len(leaked) > 0 {
+				t.Errorf("Extract wrote %v", leaked)
+			}
+		})
+	}
+
+	// Обычные пути распаковываются
+	a, err := Open("testdata/single.vpk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	if err := a.Extract(dst, a.Paths()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "models", "heroes", "hero.vmdl_c"))
+	if err != nil || string(data) != "model data" {
+		t.Errorf("extracted hero.vmdl_c = %q, %v", data, err)
+	}
+}
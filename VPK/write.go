@@ -0,0 +1,278 @@
+package vpk
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Source — файл, который нужно положить в VPK
+type Source struct {
+	Path string                        // путь внутри VPK, например panorama/images/hud/icon.vtex_c
+	Open func() (io.ReadCloser, error) // содержимое файла
+}
+
+// FileSource возвращает источник для файла на диске
+func FileSource(vpkPath, diskPath string) Source {
+	return Source{
+		Path: vpkPath,
+		Open: func() (io.ReadCloser, error) { return os.Open(diskPath) },
+	}
+}
+
+// Sources возвращает источники для всех файлов записей архива a
+func (a *Archive) Sources() []Source {
+	sources := make([]Source, 0, len(a.Entries))
+	for _, e := range a.Entries {
+		e := e
+		sources = append(sources, Source{
+			Path: e.Path,
+			Open: func() (io.ReadCloser, error) { return a.OpenEntry(e) },
+		})
+	}
+	return sources
+}
+
+// PackDir собирает все файлы папки srcDir в VPK dstPath, сохраняя пути относительно srcDir
+func PackDir(srcDir, dstPath string) error {
+	var sources []Source
+	err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, FileSource(filepath.ToSlash(rel), p))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("%s: no files to pack", srcDir)
+	}
+	return Create(dstPath, sources)
+}
+
+// treeFile — запись дерева будущего архива
+type treeFile struct {
+	ext, dir, name string
+	index          int // номер источника
+	crc            uint32
+	offset, length uint32
+}
+
+// Create пишет VPK версии 2 одним файлом _dir.vpk: все данные лежат после дерева
+// (индекс архива DirArchive), в футере — MD5 дерева, пустой секции кусков и всего
+// файла. Архив сначала собирается во временном файле рядом с dstPath и появляется
+// под своим именем только целиком. Существующий dstPath не перезаписывается.
+func Create(dstPath string, sources []Source) error {
+	files, err := splitPaths(sources)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(dstPath)
+	data, err := os.CreateTemp(dir, ".vpkdata-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		data.Close()
+		os.Remove(data.Name())
+	}()
+
+	// Данные пишутся в порядке дерева, чтобы файлы одного каталога лежали рядом
+	var offset int64
+	for i := range files {
+		f := &files[i]
+		n, crc, err := copySource(data, sources[f.index])
+		if err != nil {
+			return fmt.Errorf("%s: %w", sources[f.index].Path, err)
+		}
+		if offset+n > math.MaxUint32 {
+			return fmt.Errorf("vpk data exceeds 4 GiB")
+		}
+		f.crc, f.offset, f.length = crc, uint32(offset), uint32(n)
+		offset += n
+	}
+
+	tree := buildTree(files)
+	out, err := os.CreateTemp(dir, ".vpk-*.tmp")
+	if err != nil {
+		return err
+	}
+	outPath := out.Name()
+	defer os.Remove(outPath) // после успешного переименования файла уже нет
+
+	if err := writeArchive(out, data, tree, offset); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dstPath); err == nil {
+		return fmt.Errorf("%s: %w", dstPath, fs.ErrExist)
+	}
+	return os.Rename(outPath, dstPath)
+}
+
+// splitPaths раскладывает пути источников на расширение, каталог и имя и сортирует
+// их в порядке дерева
+func splitPaths(sources []Source) ([]treeFile, error) {
+	seen := make(map[string]bool, len(sources))
+	files := make([]treeFile, 0, len(sources))
+	for i, s := range sources {
+		p := NormalizePath(s.Path)
+		if p == "" || strings.Contains(p, "\x00") || !fs.ValidPath(p) {
+			return nil, fmt.Errorf("invalid path in vpk: %q", s.Path)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("duplicate path in vpk: %s", p)
+		}
+		seen[p] = true
+
+		dir, base := path.Split(p)
+		ext := path.Ext(base)
+		name := strings.TrimSuffix(base, ext)
+		ext = strings.TrimPrefix(ext, ".")
+		if name == "" {
+			return nil, fmt.Errorf("file without a name in vpk: %s", p)
+		}
+		if ext == "" {
+			ext = " "
+		}
+		dir = strings.TrimSuffix(dir, "/")
+		if dir == "" {
+			dir = " "
+		}
+		files = append(files, treeFile{ext: ext, dir: dir, name: name, index: i})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.ext != b.ext {
+			return a.ext < b.ext
+		}
+		if a.dir != b.dir {
+			return a.dir < b.dir
+		}
+		return a.name < b.name
+	})
+	return files, nil
+}
+
+// copySource копирует источник в w и возвращает длину и CRC32 содержимого
+func copySource(w io.Writer, s Source) (int64, uint32, error) {
+	rc, err := s.Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rc.Close()
+
+	h := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(w, h), rc)
+	return n, h.Sum32(), err
+}
+
+// buildTree собирает дерево из отсортированных записей
+func buildTree(files []treeFile) []byte {
+	var tree bytes.Buffer
+	str := func(s string) {
+		tree.WriteString(s)
+		tree.WriteByte(0)
+	}
+
+	le := binary.LittleEndian
+	var entry [entrySize]byte
+	for i := 0; i < len(files); {
+		ext := files[i].ext
+		str(ext)
+		for i < len(files) && files[i].ext == ext {
+			dir := files[i].dir
+			str(dir)
+			for i < len(files) && files[i].ext == ext && files[i].dir == dir {
+				f := files[i]
+				str(f.name)
+				le.PutUint32(entry[0:], f.crc)
+				le.PutUint16(entry[4:], 0) // без preload
+				le.PutUint16(entry[6:], DirArchive)
+				le.PutUint32(entry[8:], f.offset)
+				le.PutUint32(entry[12:], f.length)
+				le.PutUint16(entry[16:], entryTerminator)
+				tree.Write(entry[:])
+				i++
+			}
+			str("") // конец имён в каталоге
+		}
+		str("") // конец каталогов с этим расширением
+	}
+	str("") // конец расширений
+	return tree.Bytes()
+}
+
+// writeArchive пишет заголовок, дерево, данные из data и футер с контрольными суммами
+func writeArchive(out io.Writer, data *os.File, tree []byte, dataSize int64) error {
+	if int64(len(tree)) > math.MaxUint32 {
+		return errors.New("vpk tree is too large")
+	}
+
+	whole := md5.New()
+	w := io.MultiWriter(out, whole)
+
+	header := make([]byte, headerSizeV2)
+	le := binary.LittleEndian
+	le.PutUint32(header[0:], Signature)
+	le.PutUint32(header[4:], 2)
+	le.PutUint32(header[8:], uint32(len(tree)))
+	le.PutUint32(header[12:], uint32(dataSize))
+	le.PutUint32(header[16:], 0) // секция MD5 кусков пуста: кусков нет
+	le.PutUint32(header[20:], otherMD5Size)
+	le.PutUint32(header[24:], 0) // без подписи
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(tree); err != nil {
+		return err
+	}
+
+	if _, err := data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, data); err != nil {
+		return err
+	}
+
+	treeSum := md5.Sum(tree)
+	sectionSum := md5.Sum(nil) // MD5 пустой секции кусков
+	if _, err := w.Write(treeSum[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(sectionSum[:]); err != nil {
+		return err
+	}
+	_, err := out.Write(whole.Sum(nil))
+	return err
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	extractfile "DeadlockHelper/ExtractFile"
+	vpk "DeadlockHelper/VPK"
+	installlog "DeadlockHelper/installedmods"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showVPKBrowser показывает дерево файлов VPK и позволяет извлечь выбранный файл
+// или каталог целиком в папку на диске. onClosed вызывается при закрытии окна.
+func showVPKBrowser(a fyne.App, parent fyne.Window, vpkPath, title string, onClosed func()) {
+	archive, err := vpk.Open(vpkPath)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось прочитать VPK: %w", err), parent)
+		if onClosed != nil {
+			onClosed()
+		}
+		return
+	}
+
+	window := a.NewWindow("Содержимое: " + title)
+	window.Resize(fyne.NewSize(600, 500))
+	if onClosed != nil {
+		window.SetOnClosed(onClosed)
+	}
+
+	// Узлы дерева — каталоги и файлы по полному пути, корень — пустая строка
+	children := make(map[string][]string)
+	known := make(map[string]bool)
+	for _, p := range archive.Paths() {
+		for child := p; !known[child]; {
+			known[child] = true
+			parent := path.Dir(child)
+			if parent == "." {
+				parent = ""
+			}
+			children[parent] = append(children[parent], child)
+			if parent == "" {
+				break
+			}
+			child = parent
+		}
+	}
+	files := make(map[string]bool, len(archive.Entries))
+	for _, e := range archive.Entries {
+		files[e.Path] = true
+	}
+
+	tree := widget.NewTree(
+		func(uid widget.TreeNodeID) []widget.TreeNodeID { return children[uid] },
+		func(uid widget.TreeNodeID) bool { return !files[uid] },
+		func(branch bool) fyne.CanvasObject { return widget.NewLabel("") },
+		func(uid widget.TreeNodeID, branch bool, obj fyne.CanvasObject) {
+			label := path.Base(uid)
+			if e, ok := archive.Find(uid); ok && !branch {
+				label = fmt.Sprintf("%s (%s)", label, formatSize(e.Size()))
+			}
+			obj.(*widget.Label).SetText(label)
+		},
+	)
+
+	selected := ""
+	extractSelectedBtn := widget.NewButton("Извлечь выбранное", nil)
+	extractSelectedBtn.Disable()
+	tree.OnSelected = func(uid widget.TreeNodeID) {
+		selected = uid
+		extractSelectedBtn.Enable()
+	}
+
+	extract := func(patterns []string) {
+		paths := archive.Select(patterns)
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if uri == nil {
+				return
+			}
+			progress := dialog.NewProgressInfinite("Извлечение", fmt.Sprintf("Файлов: %d", len(paths)), window)
+			progress.Show()
+			go func() {
+				err := archive.Extract(uri.Path(), paths)
+				fyne.Do(func() {
+					progress.Hide()
+					if err != nil {
+						dialog.ShowError(fmt.Errorf("не удалось извлечь файлы: %w", err), window)
+						return
+					}
+					dialog.ShowInformation("Готово", fmt.Sprintf("Извлечено файлов: %d в %s", len(paths), uri.Path()), window)
+				})
+			}()
+		}, window)
+	}
+	extractSelectedBtn.OnTapped = func() { extract([]string{selected}) }
+	extractAllBtn := widget.NewButton("Извлечь всё", func() { extract(nil) })
+
+	info := widget.NewLabel(fmt.Sprintf("%s — файлов: %d", filepath.Base(vpkPath), len(archive.Entries)))
+	window.SetContent(container.NewBorder(info, container.NewHBox(extractSelectedBtn, extractAllBtn), nil, nil, tree))
+	window.Show()
+}
+
+// showOpenVPKDialog открывает VPK или скачанный архив с модом в окне просмотра
+func showOpenVPKDialog(a fyne.App, parent fyne.Window) {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, parent)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+		peekArchive(a, reader.URI().Path(), "", parent)
+	}, parent)
+}
+
+// peekArchive достаёт VPK из архива, при необходимости спрашивая пароль, и показывает его
+func peekArchive(a fyne.App, archivePath, password string, parent fyne.Window) {
+	progress := dialog.NewProgressInfinite("Открытие", filepath.Base(archivePath), parent)
+	progress.Show()
+	go func() {
+		staged, err := extractfile.Peek(archivePath, password)
+		fyne.Do(func() {
+			progress.Hide()
+			var pwErr *extractfile.PasswordError
+			if errors.As(err, &pwErr) {
+				askArchivePassword(filepath.Base(archivePath), pwErr.Wrong, parent, func(pw string) {
+					peekArchive(a, archivePath, pw, parent)
+				})
+				return
+			}
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось открыть %s: %w", filepath.Base(archivePath), err), parent)
+				return
+			}
+			showVPKBrowser(a, parent, staged.Path, filepath.Base(archivePath), staged.Discard)
+		})
+	}()
+}
+
+// formatSize возвращает размер файла в удобных единицах
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f МБ", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f КБ", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d Б", size)
+	}
+}
+
+func runExtract(args []string) error {
+	fs, root := newFlagSet("extract")
+	out := fs.String("o", ".", "папка, куда извлечь файлы")
+	password := fs.String("password", "", "пароль зашифрованного архива")
+	list := fs.Bool("list", false, "только показать подходящие файлы")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return errors.New("укажите VPK, архив мода или ID установленного мода")
+	}
+
+	vpkPath, cleanup, err := openExtractSource(fs.Arg(0), *root, *password)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	archive, err := vpk.Open(vpkPath)
+	if err != nil {
+		return err
+	}
+	paths := archive.Select(fs.Args()[1:])
+	if len(paths) == 0 {
+		return errors.New("ни один файл не подходит под шаблоны")
+	}
+	if *list {
+		for _, p := range paths {
+			fmt.Println(p)
+		}
+		return nil
+	}
+	if err := archive.Extract(*out, paths); err != nil {
+		return err
+	}
+	fmt.Printf("Извлечено файлов: %d в %s\n", len(paths), *out)
+	return nil
+}
+
+// openExtractSource находит VPK для извлечения: файл VPK, скачанный архив или
+// установленный мод по ID. cleanup убирает VPK, временно извлечённый из архива.
+func openExtractSource(source, root, password string) (string, func(), error) {
+	if _, err := os.Stat(source); err == nil {
+		staged, err := extractfile.Peek(source, password)
+		if err != nil {
+			return "", nil, err
+		}
+		return staged.Path, staged.Discard, nil
+	}
+
+	id, err := strconv.Atoi(strings.TrimSpace(source))
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: файла нет, и это не ID установленного мода", source)
+	}
+	dir, err := resolveRoot(root)
+	if err != nil {
+		return "", nil, err
+	}
+	mods, err := installlog.LoadInstalledMods(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, m := range mods {
+		if m.ID == id {
+			return m.Path, func() {}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("мод %d не найден в списке установленных", id)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	addons "DeadlockHelper/Addons"
+	config "DeadlockHelper/Config"
+	installlog "DeadlockHelper/installedmods"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// command — подкоманда, которую можно запустить из командной строки вместо окна:
+//
+//	DeadlockHelper conflicts [-root путь]
+//	DeadlockHelper pack [-root путь] [-o файл.vpk] [-name название] папка
+//	DeadlockHelper reconcile [-root путь]
+//	DeadlockHelper extract [-root путь] [-o папка] [-password пароль] [-list] источник [шаблон...]
+type command struct {
+	usage string
+	about string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"conflicts": {
+		usage: "conflicts [-root путь]",
+		about: "показать файлы игры, которые подменяют несколько модов",
+		run:   runConflicts,
+	},
+	"extract": {
+		usage: "extract [-root путь] [-o папка] [-password пароль] [-list] источник [шаблон...]",
+		about: "извлечь файлы из VPK, архива мода или установленного мода (по ID)",
+		run:   runExtract,
+	},
+	"pack": {
+		usage: "pack [-root путь] [-o файл] [-name название] папка",
+		about: "собрать VPK из папки и установить его (или записать в файл)",
+		run:   runPack,
+	},
+	"reconcile": {
+		usage: "reconcile [-root путь]",
+		about: "сверить журнал установленных модов с папкой addons",
+		run:   runReconcile,
+	},
+}
+
+// runCommand выполняет подкоманду и возвращает код завершения процесса
+func runCommand(args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage()
+		return 2
+	}
+	if err := cmd.run(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, "ошибка:", err)
+		if errors.Is(err, installlog.ErrCorruptLog) {
+			fmt.Fprintln(os.Stderr, "Откройте «Установленные моды» в окне программы, чтобы восстановить журнал из резервной копии")
+		}
+		return 1
+	}
+	return 0
+}
+
+func printUsage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Команды:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-40s %s\n", commands[name].usage, commands[name].about)
+	}
+}
+
+// newFlagSet создаёт набор флагов подкоманды с общим флагом -root. По умолчанию
+// используется путь до Deadlock из конфига.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	root := fs.String("root", "", "путь до папки Deadlock (по умолчанию из конфига)")
+	return fs, root
+}
+
+// resolveRoot возвращает путь до Deadlock из флага или из конфига
+func resolveRoot(root string) (string, error) {
+	if root != "" {
+		return root, nil
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return "", fmt.Errorf("ошибка загрузки конфига: %w", err)
+	}
+	if cfg.DeadlockPath == "" {
+		return "", errors.New("укажите путь до папки Deadlock флагом -root")
+	}
+	return cfg.DeadlockPath, nil
+}
+
+func runConflicts(args []string) error {
+	fs, root := newFlagSet("conflicts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dir, err := resolveRoot(*root)
+	if err != nil {
+		return err
+	}
+
+	report, err := addons.Conflicts(addons.Dir(dir))
+	if err != nil {
+		return err
+	}
+	names := modNames(dir)
+	for _, err := range report.Skipped {
+		fmt.Fprintln(os.Stderr, "пропущен:", err)
+	}
+	if len(report.Conflicts) == 0 {
+		fmt.Println("Конфликтов нет")
+		return nil
+	}
+	for _, c := range report.Conflicts {
+		fmt.Println(c.Asset)
+		for i, p := range c.Providers {
+			mark := "  "
+			if i == 0 {
+				mark = "* "
+			}
+			fmt.Printf("  %s%s\n", mark, providerName(p, names))
+		}
+	}
+	fmt.Printf("Конфликтующих файлов: %d (* — побеждает)\n", len(report.Conflicts))
+	return nil
+}
+
+func runReconcile(args []string) error {
+	fs, root := newFlagSet("reconcile")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dir, err := resolveRoot(*root)
+	if err != nil {
+		return err
+	}
+
+	issues, err := installlog.Reconcile(dir, nil)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		fmt.Println("Журнал совпадает с папкой addons")
+		return nil
+	}
+	for _, issue := range issues {
+		name := issue.Mod.Name
+		if issue.Kind == installlog.IssueUntracked {
+			name = filepath.Base(issue.Path)
+		}
+		fmt.Printf("%-14s %s (%s)\n", issue.Kind, name, issue.Path)
+	}
+	fmt.Printf("Расхождений: %d. Разобрать их можно в окне «Установленные моды» → «Сверить с addons»\n", len(issues))
+	return nil
+}
+
+// modNames сопоставляет файлы pak в addons с названиями установленных модов
+func modNames(dir string) map[string]string {
+	names := make(map[string]string)
+	mods, err := installlog.LoadInstalledMods(dir)
+	if err != nil {
+		return names
+	}
+	for _, m := range mods {
+		names[filepath.Base(m.Path)] = m.Name
+	}
+	return names
+}
+
+// providerName возвращает название мода и имя его pak
+func providerName(p addons.Provider, names map[string]string) string {
+	base := filepath.Base(p.Path)
+	if name, ok := names[base]; ok {
+		return fmt.Sprintf("%s (%s)", name, base)
+	}
+	return base
+}
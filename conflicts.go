@@ -0,0 +1,114 @@
+package main
+
+import (
+	addons "DeadlockHelper/Addons"
+	installlog "DeadlockHelper/installedmods"
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showConflictsWindow показывает файлы игры, которые подменяют несколько модов, и какой
+// из модов побеждает при текущем порядке загрузки
+func showConflictsWindow(a fyne.App, parent fyne.Window, dir string) {
+	report, err := addons.Conflicts(addons.Dir(dir))
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось прочитать addons: %w", err), parent)
+		return
+	}
+	names := modNames(dir)
+
+	window := a.NewWindow("Конфликты модов")
+	window.Resize(fyne.NewSize(700, 500))
+
+	summary := fmt.Sprintf("Конфликтующих файлов: %d. Побеждает мод, стоящий выше в порядке загрузки.", len(report.Conflicts))
+	if len(report.Conflicts) == 0 {
+		summary = "Конфликтов нет"
+	}
+	if len(report.Skipped) > 0 {
+		summary += fmt.Sprintf("\nНе удалось прочитать pak: %d", len(report.Skipped))
+	}
+	header := widget.NewLabel(summary)
+	header.Wrapping = fyne.TextWrapWord
+
+	// Строки разной высоты, поэтому вместо widget.List — простой столбец
+	rows := container.NewVBox()
+	for _, c := range report.Conflicts {
+		text := "победитель: " + providerName(c.Winner(), names)
+		for _, p := range c.Providers[1:] {
+			text += "\nперекрыт: " + providerName(p, names)
+		}
+		asset := widget.NewLabelWithStyle(c.Asset, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		rows.Add(container.NewVBox(asset, widget.NewLabel(text), widget.NewSeparator()))
+	}
+
+	window.SetContent(container.NewBorder(header, nil, nil, nil, container.NewVScroll(rows)))
+	window.Show()
+}
+
+// maxListedAssets — сколько пересекающихся файлов показывать для каждого мода
+const maxListedAssets = 5
+
+// askConflictPlacement показывает, какие файлы нового мода уже подменяют установленные
+// моды, и предлагает поставить новый мод выше них, ниже них или отменить установку
+func askConflictPlacement(modName string, overlaps []addons.Overlap, dir string, parent fyne.Window, onChoice func(above, ok bool)) {
+	names := modNames(dir)
+
+	rows := container.NewVBox()
+	for _, o := range overlaps {
+		text := fmt.Sprintf("%s — файлов: %d", providerName(o.Provider, names), len(o.Assets))
+		for i, asset := range o.Assets {
+			if i == maxListedAssets {
+				text += fmt.Sprintf("\n  … и ещё %d", len(o.Assets)-maxListedAssets)
+				break
+			}
+			text += "\n  " + asset
+		}
+		rows.Add(widget.NewLabel(text))
+	}
+
+	hint := widget.NewLabel(fmt.Sprintf("Мод %s заменяет файлы, которые уже заменяют установленные моды. "+
+		"Мод выше в порядке загрузки побеждает.", modName))
+	hint.Wrapping = fyne.TextWrapWord
+
+	var d *dialog.CustomDialog
+	choose := func(above, ok bool) func() {
+		return func() {
+			d.Hide()
+			onChoice(above, ok)
+		}
+	}
+	content := container.NewBorder(hint, nil, nil, nil, container.NewVScroll(rows))
+	d = dialog.NewCustomWithoutButtons("Пересечение с установленными модами", content, parent)
+	d.SetButtons([]fyne.CanvasObject{
+		widget.NewButton("Отмена", choose(false, false)),
+		widget.NewButton("Поставить ниже", choose(false, true)),
+		widget.NewButtonWithIcon("Поставить выше", theme.ConfirmIcon(), choose(true, true)),
+	})
+	d.Resize(fyne.NewSize(600, 450))
+	d.Show()
+}
+
+// overlappingMods возвращает ID установленных модов, которым принадлежат pak из overlaps
+func overlappingMods(overlaps []addons.Overlap, dir string) []int {
+	mods, err := installlog.LoadInstalledMods(dir)
+	if err != nil {
+		return nil
+	}
+	byPath := make(map[string]int, len(mods))
+	for _, m := range mods {
+		byPath[filepath.Clean(m.Path)] = m.ID
+	}
+	var ids []int
+	for _, o := range overlaps {
+		if id, ok := byPath[filepath.Clean(o.Provider.Path)]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
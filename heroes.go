@@ -0,0 +1,85 @@
+package main
+
+import (
+	installlog "DeadlockHelper/installedmods"
+	"fmt"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// noHeroGroup — группа модов, не меняющих модели героев
+const noHeroGroup = "Без героя"
+
+// askDisableSkins сообщает, что для героев нового мода уже включены другие скины, и
+// предлагает выключить их: два скина одного героя смешивают модели и материалы
+func askDisableSkins(modName string, heroes []string, skins []installlog.InstalledMod, dir string, parent fyne.Window) {
+	var names []string
+	for _, m := range skins {
+		names = append(names, m.Name)
+	}
+	message := fmt.Sprintf("Мод %s установлен. Для героев %s уже включены скины:\n%s\n\nВыключить их?",
+		modName, strings.Join(heroes, ", "), strings.Join(names, "\n"))
+
+	dialog.ShowConfirm("Другие скины героя", message, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		for _, m := range skins {
+			if err := installlog.SetModEnabled(m.ID, false, dir); err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось выключить %s: %w", m.Name, err), parent)
+				return
+			}
+		}
+	}, parent)
+}
+
+// groupByHero раскладывает карточки модов по героям. Мод, меняющий нескольких героев,
+// попадает в каждую их группу; newCard вызывается для каждого попадания отдельно.
+func groupByHero(mods []installlog.InstalledMod, newCard func(installlog.InstalledMod) fyne.CanvasObject) fyne.CanvasObject {
+	groups := make(map[string][]installlog.InstalledMod)
+	for _, m := range mods {
+		if len(m.Heroes) == 0 {
+			groups[noHeroGroup] = append(groups[noHeroGroup], m)
+			continue
+		}
+		for _, h := range m.Heroes {
+			groups[h] = append(groups[h], m)
+		}
+	}
+
+	var heroes []string
+	for h := range groups {
+		if h != noHeroGroup {
+			heroes = append(heroes, h)
+		}
+	}
+	sort.Strings(heroes)
+	if _, ok := groups[noHeroGroup]; ok {
+		heroes = append(heroes, noHeroGroup)
+	}
+
+	accordion := widget.NewAccordion()
+	accordion.MultiOpen = true
+	for _, h := range heroes {
+		grid := container.NewGridWithColumns(3)
+		enabled := 0
+		for _, m := range groups[h] {
+			grid.Add(newCard(m))
+			if m.Enabled {
+				enabled++
+			}
+		}
+		title := fmt.Sprintf("%s (%d)", h, len(groups[h]))
+		if h != noHeroGroup && enabled > 1 {
+			title += fmt.Sprintf(" — включено скинов: %d", enabled)
+		}
+		accordion.Append(widget.NewAccordionItem(title, grid))
+	}
+	accordion.OpenAll()
+	return accordion
+}
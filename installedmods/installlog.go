@@ -1,10 +1,16 @@
 package installlog
 
 import (
+	addons "DeadlockHelper/Addons"
+	store "DeadlockHelper/Store"
+	vpk "DeadlockHelper/VPK"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 )
 
@@ -14,72 +20,434 @@ type InstalledMod struct {
 	ImageURL  string    `json:"image_url"`
 	Path      string    `json:"path"` // путь к установленному VPK-файлу (название файла)
 	Installed time.Time `json:"installed"`
+	Enabled   bool      `json:"enabled"`
+	Slot      int       `json:"slot,omitempty"`    // место в порядке загрузки, запоминается и для выключенного мода
+	FileID    int       `json:"file_id,omitempty"` // ID скачанного файла на GameBanana
+	MD5       string    `json:"md5,omitempty"`     // MD5 скачанного файла по данным GameBanana
+	SHA256    string    `json:"sha256,omitempty"`  // хеш VPK в хранилище
+	Heroes    []string  `json:"heroes,omitempty"`  // герои, чьи модели меняет мод
+	Problem   string    `json:"problem,omitempty"` // почему VPK не прошёл последнюю проверку
+	// Sources — моды, объединённые в этот составной pak, в порядке загрузки.
+	// Их VPK лежат в хранилище, поэтому составной мод можно разделить обратно.
+	Sources []InstalledMod `json:"sources,omitempty"`
+}
+
+// IsLocal сообщает, что мод установлен не с GameBanana и скачать его заново нельзя
+func (m InstalledMod) IsLocal() bool {
+	return m.ID <= 0
+}
+
+// UnmarshalJSON считает включёнными моды из старых журналов, где поля enabled ещё не было
+func (m *InstalledMod) UnmarshalJSON(data []byte) error {
+	type plain InstalledMod
+	p := plain{Enabled: true}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*m = InstalledMod(p)
+	return nil
 }
 
 var logFileName = "installed_mods.json"
 
-// SaveInstalledMod сохраняет информацию об установленном моде в файл
+// SaveInstalledMod добавляет установленный мод в журнал
 func SaveInstalledMod(mod InstalledMod, dir string) error {
-	filePath := filepath.Join(dir, logFileName)
-
-	var mods []InstalledMod
-	if data, err := os.ReadFile(filePath); err == nil {
-		_ = json.Unmarshal(data, &mods)
-	}
+	return update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		return append(mods, mod), nil
+	})
+}
 
-	mods = append(mods, mod)
+// SaveLocalMod добавляет в журнал мод, собранный локально, и выдаёт ему ID.
+// ID выбирается под тем же замком, что и запись, поэтому два мода, собранные
+// одновременно, не получат один ID.
+func SaveLocalMod(mod InstalledMod, dir string) (InstalledMod, error) {
+	err := update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		mod.ID = nextLocalID(mods)
+		return append(mods, mod), nil
+	})
+	return mod, err
+}
 
-	data, err := json.MarshalIndent(mods, "", "  ")
+// DeleteInstalledMod удаляет мод из журнала вместе с его файлом. Файл удаляется
+// только после того, как журнал сохранён.
+func DeleteInstalledMod(id int, dir string) error {
+	var deletePath string
+	err := update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		var updated []InstalledMod
+		for _, m := range mods {
+			if m.ID == id {
+				deletePath = m.Path
+				continue
+			}
+			updated = append(updated, m)
+		}
+		return updated, nil
+	})
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filePath, data, 0644)
+
+	// Удаляем файл мода
+	if deletePath != "" {
+		_ = os.RemoveAll(deletePath)
+	}
+	return nil
 }
-func DeleteInstalledMod(id int, dir string) error {
-	filePath := filepath.Join(dir, "installed_mods.json")
-	data, err := os.ReadFile(filePath)
+
+// LoadInstalledMods читает журнал установленных модов. Журнал старого формата
+// переводится в текущий, повреждённый возвращает ErrCorruptLog.
+func LoadInstalledMods(dir string) ([]InstalledMod, error) {
+	unlock, err := lockLog(dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer unlock()
+	return readLog(dir)
+}
 
-	var mods []InstalledMod
-	if err := json.Unmarshal(data, &mods); err != nil {
-		return err
+// SortByLoadOrder сортирует моды по номеру pak: первым идёт мод с наибольшим приоритетом.
+// Выключенный мод стоит перед модом, который сейчас занимает его прежний слот.
+// Моды без слота оказываются в конце.
+func SortByLoadOrder(mods []InstalledMod) {
+	sort.SliceStable(mods, func(i, j int) bool {
+		si, sj := loadOrderSlot(mods[i]), loadOrderSlot(mods[j])
+		if si != sj {
+			return si < sj
+		}
+		return !mods[i].Enabled && mods[j].Enabled
+	})
+}
+
+// loadOrderSlot возвращает слот мода: по имени файла для включённого, запомненный для выключенного
+func loadOrderSlot(m InstalledMod) int {
+	if m.Enabled {
+		if slot, ok := addons.ParseSlot(m.Path); ok {
+			return slot
+		}
 	}
+	if m.Slot > 0 {
+		return m.Slot
+	}
+	return addons.MaxSlot + 1
+}
 
+// ApplyLoadOrder раскладывает pak в addons в порядке ordered и сохраняет журнал в
+// этом же порядке. Из ordered берётся только порядок ID: сами записи читаются из
+// журнала под замком, моды, установленные после того, как ordered был составлен,
+// встают в конец, а удалённые за это время пропускаются. Возвращает моды журнала
+// в новом порядке.
+func ApplyLoadOrder(ordered []InstalledMod, dir string) ([]InstalledMod, error) {
 	var updated []InstalledMod
-	var deletePath string
+	err := update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		SortByLoadOrder(mods)
+		var err error
+		if updated, err = applyLoadOrder(reorder(mods, ordered), dir); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	})
+	return updated, err
+}
+
+// reorder расставляет mods в порядке ID из ordered. Моды, которых в ordered нет,
+// сохраняют свой порядок и идут в конце.
+func reorder(mods, ordered []InstalledMod) []InstalledMod {
+	result := make([]InstalledMod, 0, len(mods))
+	placed := make(map[int]bool, len(mods))
+	for _, o := range ordered {
+		if i := findMod(mods, o.ID); i >= 0 && !placed[o.ID] {
+			result = append(result, mods[i])
+			placed[o.ID] = true
+		}
+	}
 	for _, m := range mods {
-		if m.ID == id {
-			deletePath = m.Path
+		if !placed[m.ID] {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// applyLoadOrder переименовывает VPK модов в addons так, чтобы номера pak шли в порядке
+// ordered, и возвращает моды с обновлёнными Path и Slot. Журнал не сохраняет, вызывается
+// из update. Выключенные моды и моды, файлов которых нет в addons, не переименовываются,
+// но запоминают своё место в порядке. При ошибке имена файлов не меняются.
+func applyLoadOrder(ordered []InstalledMod, dir string) ([]InstalledMod, error) {
+	addonsDir := addons.Dir(dir)
+
+	var paths []string
+	var indexes []int
+	for i, m := range ordered {
+		if !m.Enabled || m.Path == "" || filepath.Dir(m.Path) != addonsDir {
+			continue
+		}
+		if _, err := os.Stat(m.Path); err != nil {
 			continue
 		}
-		updated = append(updated, m)
+		paths = append(paths, m.Path)
+		indexes = append(indexes, i)
 	}
 
-	// Удаляем файл мода
-	if deletePath != "" {
-		_ = os.RemoveAll(deletePath)
+	newPaths, err := addons.Renumber(addonsDir, paths)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось переименовать моды: %w", err)
+	}
+
+	updated := make([]InstalledMod, len(ordered))
+	copy(updated, ordered)
+	for k, i := range indexes {
+		updated[i].Path = newPaths[k]
+	}
+
+	// Мод без файла в addons занимает место перед следующим за ним включённым модом
+	nextSlot := addons.MaxSlot + 1
+	if len(newPaths) > 0 {
+		last, _ := addons.ParseSlot(newPaths[len(newPaths)-1])
+		nextSlot = last + 1
+	}
+	for i := len(updated) - 1; i >= 0; i-- {
+		if slot, ok := addons.ParseSlot(updated[i].Path); ok && updated[i].Enabled {
+			nextSlot = slot
+		}
+		updated[i].Slot = nextSlot
 	}
+	return updated, nil
+}
+
+// SetModEnabled включает или выключает мод, не удаляя его. Выключенный VPK переносится
+// в папку выключенных модов вне путей поиска игры, включённый возвращается на прежнее
+// место в порядке загрузки.
+func SetModEnabled(id int, enabled bool, dir string) error {
+	return update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		index := findMod(mods, id)
+		if index < 0 {
+			return nil, fmt.Errorf("мод %d не найден в списке установленных", id)
+		}
+		mod := &mods[index]
+		if mod.Enabled == enabled {
+			return mods, nil
+		}
+
+		if !enabled {
+			if err := disableMod(mod, dir); err != nil {
+				return nil, err
+			}
+			return mods, nil
+		}
+
+		if err := restoreFromStore(mod); err != nil {
+			return nil, err
+		}
+		newPath, err := addons.Enable(mod.Path, dir, mod.Slot)
+		if err != nil {
+			return nil, err
+		}
+		mod.Path = newPath
+		mod.Enabled = true
+		if _, ok := addons.ParseSlot(newPath); ok {
+			mod.Slot = 0
+			return mods, nil
+		}
+
+		// Прежний слот занят: ставим мод перед тем, кто его занял, и перенумеровываем
+		mod.Enabled = false // чтобы сортировка поставила его перед занявшим слот модом
+		SortByLoadOrder(mods)
+		mods[findMod(mods, id)].Enabled = true
+		updated, err := applyLoadOrder(mods, dir)
+		if err != nil {
+			// Файл уже вернулся в addons под временным именем, журнал должен это знать
+			return mods, err
+		}
+		return updated, nil
+	})
+}
 
-	newData, err := json.MarshalIndent(updated, "", "  ")
+// disableMod переносит VPK включённого мода в папку выключенных и запоминает его слот
+func disableMod(mod *InstalledMod, dir string) error {
+	slot, _ := addons.ParseSlot(mod.Path)
+	newPath, err := addons.Disable(mod.Path, dir, mod.ID)
 	if err != nil {
 		return err
 	}
+	mod.Path = newPath
+	mod.Slot = slot
+	mod.Enabled = false
+	return nil
+}
 
-	return os.WriteFile(filePath, newData, 0644)
+// restoreFromStore заново создаёт файл мода из хранилища, если его удалили с диска
+func restoreFromStore(mod *InstalledMod) error {
+	if mod.SHA256 == "" || mod.Path == "" {
+		return nil
+	}
+	if _, err := os.Stat(mod.Path); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if !store.Has(mod.SHA256) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(mod.Path), 0755); err != nil {
+		return err
+	}
+	return store.Link(mod.SHA256, mod.Path)
 }
-func LoadInstalledMods(dir string) ([]InstalledMod, error) {
-	filePath := filepath.Join(dir, "installed_mods.json")
-	data, err := os.ReadFile(filePath)
+
+// findMod возвращает индекс мода с указанным ID или -1
+func findMod(mods []InstalledMod, id int) int {
+	for i, m := range mods {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// PlaceMod переставляет мод id в порядке загрузки сразу выше первого (above) или сразу
+// ниже последнего из модов relativeTo и применяет порядок. Если ни одного из модов
+// relativeTo нет в журнале, порядок не меняется.
+func PlaceMod(id int, relativeTo []int, above bool, dir string) error {
+	return update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		SortByLoadOrder(mods)
+
+		index := findMod(mods, id)
+		if index < 0 {
+			return nil, fmt.Errorf("мод %d не найден в списке установленных", id)
+		}
+		mod := mods[index]
+		rest := append(append([]InstalledMod{}, mods[:index]...), mods[index+1:]...)
+
+		others := make(map[int]bool, len(relativeTo))
+		for _, other := range relativeTo {
+			others[other] = true
+		}
+		pos := -1
+		for i, m := range rest {
+			if !others[m.ID] {
+				continue
+			}
+			if above {
+				pos = i
+				break
+			}
+			pos = i + 1
+		}
+		if pos < 0 {
+			return mods, nil
+		}
+
+		rest = append(rest[:pos], append([]InstalledMod{mod}, rest[pos:]...)...)
+		return applyLoadOrder(rest, dir)
+	})
+}
+
+// ActiveSkins возвращает включённые моды, кроме мода exceptID, которые меняют модели
+// хотя бы одного из героев heroes
+func ActiveSkins(heroes []string, exceptID int, dir string) ([]InstalledMod, error) {
+	if len(heroes) == 0 {
+		return nil, nil
+	}
+	mods, err := LoadInstalledMods(dir)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(heroes))
+	for _, h := range heroes {
+		wanted[h] = true
+	}
+
+	var skins []InstalledMod
+	for _, m := range mods {
+		if m.ID == exceptID || !m.Enabled {
+			continue
+		}
+		for _, h := range m.Heroes {
+			if wanted[h] {
+				skins = append(skins, m)
+				break
+			}
+		}
+	}
+	return skins, nil
+}
+
+// DetectHeroes определяет героев для модов, установленных до появления этого поля,
+// и сохраняет журнал, если что-то нашлось
+func DetectHeroes(mods []InstalledMod, dir string) ([]InstalledMod, error) {
+	detected := make(map[int][]string)
+	for i := range mods {
+		if len(mods[i].Heroes) > 0 || mods[i].Path == "" {
+			continue
+		}
+		heroes, err := addons.Heroes(mods[i].Path)
+		if err != nil || len(heroes) == 0 {
+			continue
+		}
+		mods[i].Heroes = heroes
+		detected[mods[i].ID] = heroes
+	}
+	if len(detected) == 0 {
+		return mods, nil
 	}
+	return mods, setFields(dir, func(m *InstalledMod) {
+		if heroes, ok := detected[m.ID]; ok {
+			m.Heroes = heroes
+		}
+	})
+}
 
-	var mods []InstalledMod
-	err = json.Unmarshal(data, &mods)
+// setFields применяет set к каждому моду журнала и сохраняет его. Нужна, чтобы
+// записать результаты долгой проверки, не затерев моды, установленные за это время.
+func setFields(dir string, set func(m *InstalledMod)) error {
+	return update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		for i := range mods {
+			set(&mods[i])
+		}
+		return mods, nil
+	})
+}
+
+// VerifyMods проверяет VPK всех установленных модов (см. vpk.Verify), записывает
+// найденные проблемы в InstalledMod.Problem и возвращает моды, не прошедшие проверку.
+// progress вызывается перед проверкой каждого мода и может быть nil.
+func VerifyMods(dir string, progress func(done, total int, name string)) ([]InstalledMod, error) {
+	mods, err := LoadInstalledMods(dir)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка разбора JSON: %w", err)
+		return nil, err
+	}
+
+	var failed []InstalledMod
+	for i := range mods {
+		if progress != nil {
+			progress(i, len(mods), mods[i].Name)
+		}
+		mods[i].Problem = ""
+		if err := vpk.Verify(mods[i].Path); err != nil {
+			mods[i].Problem = err.Error()
+			failed = append(failed, mods[i])
+		}
+	}
+	if progress != nil {
+		progress(len(mods), len(mods), "")
+	}
+
+	problems := make(map[int]string, len(mods))
+	for _, m := range mods {
+		problems[m.ID] = m.Problem
+	}
+	return failed, setFields(dir, func(m *InstalledMod) {
+		if problem, ok := problems[m.ID]; ok {
+			m.Problem = problem
+		}
+	})
+}
+
+// nextLocalID возвращает ID меньше всех ID локальных модов из mods
+func nextLocalID(mods []InstalledMod) int {
+	id := -1
+	for _, m := range mods {
+		if m.ID <= id {
+			id = m.ID - 1
+		}
 	}
-	return mods, nil
+	return id
 }
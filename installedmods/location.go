@@ -0,0 +1,246 @@
+package installlog
+
+import (
+	config "DeadlockHelper/Config"
+	"DeadlockHelper/internal/fsutil"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Журнал хранится не в папке игры, а в папке настроек программы:
+//
+//	~/.deadlockhelper/installs/<ключ>/installed_mods.json
+//
+// Ключ — хеш пути до папки Deadlock, поэтому у каждой установки игры свой журнал,
+// и он переживает переустановку игры в ту же папку. Рядом лежит install.json с
+// самим путём, чтобы установки можно было перечислить. Журнал, который старые
+// версии программы писали в папку игры, переносится сюда при первом обращении.
+
+const (
+	installsDirName = "installs"
+	installInfoName = "install.json"
+)
+
+// Install — установка Deadlock, для которой программа ведёт журнал
+type Install struct {
+	Key  string `json:"-"`
+	Root string `json:"root"` // путь до папки Deadlock
+}
+
+// installKey возвращает ключ установки по пути до папки Deadlock. Пути, ведущие в
+// одну папку (относительный, через символическую ссылку, в другом регистре на
+// Windows), дают один ключ.
+func installKey(root string) (string, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	if runtime.GOOS == "windows" {
+		abs = strings.ToLower(abs)
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(abs)))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// installDir возвращает папку с журналом установки root, создавая её при необходимости
+func installDir(root string) (string, error) {
+	key, err := installKey(root)
+	if err != nil {
+		return "", err
+	}
+	base, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, installsDirName, key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	// Путь в install.json обновляется, если до той же папки пришли другим путём,
+	// например после переименования ссылки на неё
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	infoPath := filepath.Join(dir, installInfoName)
+	var info Install
+	if data, err := os.ReadFile(infoPath); err == nil {
+		_ = json.Unmarshal(data, &info)
+	}
+	if info.Root != abs {
+		data, err := json.MarshalIndent(Install{Root: abs}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		if err := fsutil.WriteFileAtomic(infoPath, data); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func logPath(root string) (string, error) {
+	dir, err := installDir(root)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, logFileName), nil
+}
+
+// migrated — журналы (по пути в папке настроек), для которых перенос из папки игры
+// уже сделан в этом процессе. Журнал в папке игры оставляют только старые версии
+// программы, поэтому заглядывать туда при каждом обращении к журналу незачем.
+var migrated sync.Map
+
+// migrateLegacy переносит журнал и его резервные копии из папки игры root в папку
+// настроек. Если там журнал уже есть, старый файл становится его резервной копией.
+// Для каждой установки работает один раз за запуск. Вызывается под lockLog.
+func migrateLegacy(root, path string) error {
+	if _, ok := migrated.Load(path); ok {
+		return nil
+	}
+	if err := migrateLegacyOnce(root, path); err != nil {
+		return err
+	}
+	migrated.Store(path, true)
+	return nil
+}
+
+func migrateLegacyOnce(root, path string) error {
+	legacy := filepath.Join(root, logFileName)
+	data, err := os.ReadFile(legacy)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать журнал в папке игры: %w", err)
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		err = fsutil.WriteFileAtomic(path, data)
+	} else {
+		version, _ := schemaOf(data)
+		err = backupLog(path, data, version)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось перенести журнал из папки игры: %w", err)
+	}
+	if err := os.Remove(legacy); err != nil {
+		return err
+	}
+	os.Remove(legacy + lockSuffix)
+
+	// Резервные и повреждённые копии тоже переезжают, чтобы RecoverLog их нашёл
+	extras, _ := filepath.Glob(legacy + ".*")
+	for _, extra := range extras {
+		dst := filepath.Join(filepath.Dir(path), filepath.Base(extra))
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err := moveFile(extra, dst); err != nil {
+			return fmt.Errorf("не удалось перенести %s: %w", filepath.Base(extra), err)
+		}
+	}
+	return nil
+}
+
+// moveFile переносит небольшой файл, в том числе на другой диск: папка игры
+// часто лежит не там, где папка настроек
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(dst, data); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// Installs возвращает все установки игры, для которых есть журнал
+func Installs() ([]Install, error) {
+	base, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(base, installsDirName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var installs []Install
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(base, installsDirName, e.Name())
+		data, err := os.ReadFile(filepath.Join(dir, installInfoName))
+		if err != nil {
+			continue
+		}
+		var inst Install
+		if err := json.Unmarshal(data, &inst); err != nil || inst.Root == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, logFileName)); err != nil {
+			continue
+		}
+		inst.Key = e.Name()
+		installs = append(installs, inst)
+	}
+	return installs, nil
+}
+
+// StoreHashes возвращает хеши VPK в хранилище, на которые ссылаются журналы всех
+// установок игры, включая моды внутри составных. Хранилище общее, поэтому чистить
+// его по журналу одной установки нельзя. Если какой-то журнал не читается,
+// возвращается ошибка: иначе очистка удалила бы файлы его модов.
+func StoreHashes() (map[string]bool, error) {
+	installs, err := Installs()
+	if err != nil {
+		return nil, err
+	}
+	base, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool)
+	for _, inst := range installs {
+		// Журнал читается по ключу папки, а не по пути игры: путь мог измениться.
+		// Замок не нужен, журнал всегда заменяется целиком.
+		data, err := os.ReadFile(filepath.Join(base, installsDirName, inst.Key, logFileName))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", inst.Root, err)
+		}
+		mods, _, err := decodeLog(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", inst.Root, err)
+		}
+		for _, m := range mods {
+			keep[m.SHA256] = true
+			for _, src := range m.Sources {
+				keep[src.SHA256] = true
+			}
+		}
+	}
+	return keep, nil
+}
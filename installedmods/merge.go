@@ -0,0 +1,286 @@
+package installlog
+
+import (
+	addons "DeadlockHelper/Addons"
+	extractfile "DeadlockHelper/ExtractFile"
+	store "DeadlockHelper/Store"
+	vpk "DeadlockHelper/VPK"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IsComposite сообщает, что мод собран из нескольких модов и его можно разделить
+func (m InstalledMod) IsComposite() bool {
+	return len(m.Sources) > 0
+}
+
+// parkSuffix добавляется к VPK, который убран из addons, но ещё не удалён: так он
+// перестаёт занимать слот, а при ошибке его можно вернуть на место
+const parkSuffix = ".removing"
+
+// Merge объединяет включённые моды ids в один pak с названием name. Если несколько
+// модов содержат один и тот же файл, в составной pak попадает версия мода, стоящего
+// выше в порядке загрузки, то есть та, которую игра и так видела. Составной мод
+// занимает слот самого приоритетного из исходных, а исходные VPK остаются в
+// хранилище, чтобы Split мог вернуть их обратно.
+//
+// Исходные VPK удаляются из addons только после записи журнала. Если записать
+// журнал не удалось, составной pak удаляется, а исходные возвращаются на место.
+func Merge(ids []int, name, dir string) (InstalledMod, error) {
+	if err := addons.EnsureGameClosed(); err != nil {
+		return InstalledMod{}, err
+	}
+	if len(ids) < 2 {
+		return InstalledMod{}, errors.New("для объединения выберите хотя бы два мода")
+	}
+
+	var composite InstalledMod
+	var parked []string
+	err := update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		SortByLoadOrder(mods)
+
+		selected := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			selected[id] = true
+		}
+		var sources []InstalledMod
+		var archives []*vpk.Archive
+		for _, m := range mods {
+			if !selected[m.ID] {
+				continue
+			}
+			if !m.Enabled {
+				return nil, fmt.Errorf("мод %s выключен, включите его перед объединением", m.Name)
+			}
+			a, err := vpk.Open(m.Path)
+			if err != nil {
+				return nil, fmt.Errorf("не удалось прочитать %s: %w", m.Name, err)
+			}
+			// Без копии в хранилище исходный мод нельзя будет вернуть при разделении
+			if m.SHA256 == "" || !store.Has(m.SHA256) {
+				if m.SHA256, err = store.AddFile(m.Path); err != nil {
+					return nil, fmt.Errorf("не удалось сохранить %s в хранилище: %w", m.Name, err)
+				}
+			}
+			sources = append(sources, m)
+			archives = append(archives, a)
+		}
+		if len(sources) != len(ids) {
+			return nil, errors.New("некоторые из выбранных модов не установлены")
+		}
+
+		installed, err := buildComposite(archives, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		// Исходные VPK уходят под скрытые имена, и составной занимает слот первого из них
+		paths := make([]string, len(sources))
+		for i, src := range sources {
+			paths[i] = src.Path
+		}
+		if err := parkFiles(paths); err != nil {
+			os.Remove(installed.Path)
+			return nil, fmt.Errorf("не удалось убрать исходные моды из addons: %w", err)
+		}
+		path := installed.Path
+		if slot, ok := addons.ParseSlot(paths[0]); ok {
+			if path, err = addons.InstallAt(installed.Path, addons.Dir(dir), slot); err != nil {
+				os.Remove(installed.Path)
+				unparkFiles(paths)
+				return nil, err
+			}
+		}
+
+		heroes, _ := addons.Heroes(path)
+		for i := range sources {
+			sources[i].Path = ""
+		}
+		composite = InstalledMod{
+			ID:        nextLocalID(mods),
+			Name:      name,
+			Path:      path,
+			Installed: time.Now(),
+			Enabled:   true,
+			SHA256:    installed.SHA256,
+			Heroes:    heroes,
+			Sources:   sources,
+		}
+		parked = paths
+
+		// Составной мод встаёт на место первого исходного
+		updated := make([]InstalledMod, 0, len(mods)-len(sources)+1)
+		for _, m := range mods {
+			switch {
+			case m.ID == sources[0].ID:
+				updated = append(updated, composite)
+			case selected[m.ID]:
+			default:
+				updated = append(updated, m)
+			}
+		}
+		return updated, nil
+	})
+	if err != nil {
+		if parked != nil {
+			os.Remove(composite.Path)
+			unparkFiles(parked)
+		}
+		return InstalledMod{}, err
+	}
+	removeParked(parked)
+	return composite, nil
+}
+
+// buildComposite собирает из archives один pak и ставит его в следующий свободный
+// слот addons. Первый встреченный файл побеждает: архивы идут в порядке загрузки.
+func buildComposite(archives []*vpk.Archive, dir string) (extractfile.Installed, error) {
+	seen := make(map[string]bool)
+	var files []vpk.Source
+	for _, a := range archives {
+		for _, src := range a.Sources() {
+			if !seen[src.Path] {
+				seen[src.Path] = true
+				files = append(files, src)
+			}
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "mod_merge_")
+	if err != nil {
+		return extractfile.Installed{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+	packed := filepath.Join(tmpDir, "pak01_dir.vpk")
+	if err := vpk.Create(packed, files); err != nil {
+		return extractfile.Installed{}, fmt.Errorf("не удалось собрать составной pak: %w", err)
+	}
+	staged, err := extractfile.Stage(packed, "")
+	if err != nil {
+		return extractfile.Installed{}, err
+	}
+	defer staged.Discard()
+	return staged.Install(dir)
+}
+
+// Split разделяет составной мод id обратно на исходные моды. Они возвращаются из
+// хранилища на место составного в прежнем порядке, составной pak удаляется после
+// записи журнала. Если записать журнал не удалось, всё возвращается как было.
+func Split(id int, dir string) error {
+	if err := addons.EnsureGameClosed(); err != nil {
+		return err
+	}
+
+	var restored []InstalledMod
+	var parked []string
+	var ordered []InstalledMod
+	err := update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		SortByLoadOrder(mods)
+
+		index := findMod(mods, id)
+		if index < 0 {
+			return nil, fmt.Errorf("мод %d не найден в списке установленных", id)
+		}
+		composite := mods[index]
+		if !composite.IsComposite() {
+			return nil, fmt.Errorf("мод %s не составной", composite.Name)
+		}
+		if !composite.Enabled {
+			return nil, fmt.Errorf("мод %s выключен, включите его перед разделением", composite.Name)
+		}
+		for _, src := range composite.Sources {
+			if !store.Has(src.SHA256) {
+				return nil, fmt.Errorf("VPK мода %s пропал из хранилища, разделить нельзя", src.Name)
+			}
+		}
+
+		addonsDir := addons.Dir(dir)
+		removeRestored := func() {
+			for _, r := range restored {
+				os.Remove(r.Path)
+			}
+			restored = nil
+		}
+		for _, src := range composite.Sources {
+			path, err := store.Install(src.SHA256, addonsDir)
+			if err != nil {
+				removeRestored()
+				return nil, fmt.Errorf("не удалось вернуть %s: %w", src.Name, err)
+			}
+			src.Path = path
+			src.Enabled = true
+			restored = append(restored, src)
+		}
+
+		// Составной pak уходит под скрытое имя, а первый исходный занимает его слот.
+		// Остальные пока стоят в конце, порядок применяется после записи журнала.
+		if err := parkFiles([]string{composite.Path}); err != nil {
+			removeRestored()
+			return nil, fmt.Errorf("не удалось убрать %s из addons: %w", composite.Name, err)
+		}
+		if slot, ok := addons.ParseSlot(composite.Path); ok {
+			path, err := addons.InstallAt(restored[0].Path, addonsDir, slot)
+			if err != nil {
+				removeRestored()
+				unparkFiles([]string{composite.Path})
+				return nil, err
+			}
+			restored[0].Path = path
+		}
+		parked = []string{composite.Path}
+
+		ordered = make([]InstalledMod, 0, len(mods)+len(restored)-1)
+		ordered = append(ordered, mods[:index]...)
+		ordered = append(ordered, restored...)
+		ordered = append(ordered, mods[index+1:]...)
+		return ordered, nil
+	})
+	if err != nil {
+		if parked != nil {
+			for _, r := range restored {
+				os.Remove(r.Path)
+			}
+			unparkFiles(parked)
+		}
+		return err
+	}
+	removeParked(parked)
+
+	if _, err := ApplyLoadOrder(ordered, dir); err != nil {
+		return fmt.Errorf("моды разделены, но порядок загрузки не применён: %w", err)
+	}
+	return nil
+}
+
+// parkFiles убирает файлы paths под скрытые имена. При ошибке уже убранные
+// файлы возвращаются на место.
+func parkFiles(paths []string) error {
+	for i, p := range paths {
+		if err := os.Rename(p, p+parkSuffix); err != nil {
+			unparkFiles(paths[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// unparkFiles возвращает файлы, убранные parkFiles
+func unparkFiles(paths []string) {
+	for _, p := range paths {
+		if err := os.Rename(p+parkSuffix, p); err != nil {
+			fmt.Println("Failed to restore", p, err)
+		}
+	}
+}
+
+// removeParked удаляет файлы, убранные parkFiles, после того как журнал записан
+func removeParked(paths []string) {
+	for _, p := range paths {
+		if err := os.Remove(p + parkSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Println("Failed to remove", p+parkSuffix, err)
+		}
+	}
+}
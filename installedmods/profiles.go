@@ -0,0 +1,95 @@
+package installlog
+
+import (
+	addons "DeadlockHelper/Addons"
+	"fmt"
+)
+
+// Profile — именованный набор модов: какие из установленных включены и в каком порядке
+type Profile struct {
+	Name string       `json:"name"`
+	Mods []ProfileMod `json:"mods"` // в порядке загрузки, первый имеет наибольший приоритет
+}
+
+type ProfileMod struct {
+	ID      int  `json:"id"`
+	Enabled bool `json:"enabled"`
+}
+
+// SnapshotProfile записывает текущее состояние установленных модов (какие включены
+// и в каком порядке) в профиль с именем name
+func SnapshotProfile(name, dir string) (Profile, error) {
+	mods, err := LoadInstalledMods(dir)
+	if err != nil {
+		return Profile{}, err
+	}
+	SortByLoadOrder(mods)
+
+	profile := Profile{Name: name}
+	for _, m := range mods {
+		profile.Mods = append(profile.Mods, ProfileMod{ID: m.ID, Enabled: m.Enabled})
+	}
+	return profile, nil
+}
+
+// ApplyProfile включает и выключает моды по профилю и раскладывает pak в addons в порядке
+// профиля. Установленные моды, которых нет в профиле, выключаются. Возвращает ID модов
+// из профиля, которые сейчас не установлены. Пока игра запущена, ничего не меняет.
+func ApplyProfile(profile Profile, dir string) ([]int, error) {
+	if err := addons.EnsureGameClosed(); err != nil {
+		return nil, err
+	}
+
+	var missing []int
+	err := update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		SortByLoadOrder(mods)
+
+		wanted := make(map[int]bool)
+		for _, pm := range profile.Mods {
+			if findMod(mods, pm.ID) < 0 {
+				missing = append(missing, pm.ID)
+				continue
+			}
+			wanted[pm.ID] = pm.Enabled
+		}
+
+		// Сначала выключаем лишние моды, затем возвращаем нужные в addons под временными
+		// именами. Если что-то сломалось на полпути, журнал сохраняется как есть, чтобы он
+		// соответствовал файлам на диске.
+		for i := range mods {
+			if mods[i].Enabled && !wanted[mods[i].ID] {
+				if err := disableMod(&mods[i], dir); err != nil {
+					return mods, fmt.Errorf("не удалось выключить %s: %w", mods[i].Name, err)
+				}
+			}
+		}
+		for i := range mods {
+			if !mods[i].Enabled && wanted[mods[i].ID] {
+				if err := restoreFromStore(&mods[i]); err != nil {
+					return mods, fmt.Errorf("не удалось включить %s: %w", mods[i].Name, err)
+				}
+				pending, err := addons.Restore(mods[i].Path, dir)
+				if err != nil {
+					return mods, fmt.Errorf("не удалось включить %s: %w", mods[i].Name, err)
+				}
+				mods[i].Path = pending
+				mods[i].Enabled = true
+			}
+		}
+
+		// Порядок: сначала моды профиля в его порядке, затем остальные
+		ordered := make([]InstalledMod, len(profile.Mods))
+		for i, pm := range profile.Mods {
+			ordered[i].ID = pm.ID
+		}
+		updated, err := applyLoadOrder(reorder(mods, ordered), dir)
+		if err != nil {
+			return mods, err
+		}
+		return updated, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
@@ -0,0 +1,142 @@
+package installlog
+
+import (
+	"DeadlockHelper/internal/fsutil"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Профили ссылаются на ID модов из журнала, поэтому у каждой установки игры они
+// свои и лежат рядом с журналом: installs/<ключ>/profiles.json. Правятся они под
+// теми же замками, что и журнал.
+
+const profilesFileName = "profiles.json"
+
+// ProfileSet — профили установки игры и последний применённый из них
+type ProfileSet struct {
+	Profiles []Profile `json:"profiles"`
+	Active   string    `json:"active,omitempty"`
+}
+
+// Find возвращает профиль по имени
+func (s ProfileSet) Find(name string) (Profile, bool) {
+	for _, p := range s.Profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Profile{}, false
+}
+
+// LoadProfiles возвращает профили установки игры dir
+func LoadProfiles(dir string) (ProfileSet, error) {
+	unlock, err := lockLog(dir)
+	if err != nil {
+		return ProfileSet{}, err
+	}
+	defer unlock()
+	return readProfiles(dir)
+}
+
+// SaveProfile добавляет профиль установке dir или заменяет профиль с тем же именем
+// и делает его активным
+func SaveProfile(profile Profile, dir string) error {
+	return updateProfiles(dir, func(set *ProfileSet) {
+		replaced := false
+		for i, p := range set.Profiles {
+			if p.Name == profile.Name {
+				set.Profiles[i] = profile
+				replaced = true
+			}
+		}
+		if !replaced {
+			set.Profiles = append(set.Profiles, profile)
+		}
+		set.Active = profile.Name
+	})
+}
+
+// DeleteProfile удаляет профиль установки dir
+func DeleteProfile(name, dir string) error {
+	return updateProfiles(dir, func(set *ProfileSet) {
+		var profiles []Profile
+		for _, p := range set.Profiles {
+			if p.Name != name {
+				profiles = append(profiles, p)
+			}
+		}
+		set.Profiles = profiles
+		if set.Active == name {
+			set.Active = ""
+		}
+	})
+}
+
+// SetActiveProfile запоминает последний применённый к установке dir профиль
+func SetActiveProfile(name, dir string) error {
+	return updateProfiles(dir, func(set *ProfileSet) {
+		set.Active = name
+	})
+}
+
+// updateProfiles читает профили установки dir, передаёт их в fn и сохраняет
+// результат, не отпуская замков журнала
+func updateProfiles(dir string, fn func(set *ProfileSet)) error {
+	unlock, err := lockLog(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	set, err := readProfiles(dir)
+	if err != nil {
+		return err
+	}
+	fn(&set)
+	return writeProfiles(dir, set)
+}
+
+func profilesPath(dir string) (string, error) {
+	base, err := installDir(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, profilesFileName), nil
+}
+
+// readProfiles читает профили установки dir. Отсутствующий файл считается пустым.
+// Вызывается под lockLog.
+func readProfiles(dir string) (ProfileSet, error) {
+	path, err := profilesPath(dir)
+	if err != nil {
+		return ProfileSet{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ProfileSet{}, nil
+	}
+	if err != nil {
+		return ProfileSet{}, err
+	}
+	var set ProfileSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return ProfileSet{}, fmt.Errorf("профили повреждены: %w", err)
+	}
+	return set, nil
+}
+
+func writeProfiles(dir string, set ProfileSet) error {
+	path, err := profilesPath(dir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data)
+}
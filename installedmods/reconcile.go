@@ -0,0 +1,295 @@
+package installlog
+
+import (
+	addons "DeadlockHelper/Addons"
+	store "DeadlockHelper/Store"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IssueKind — вид расхождения между журналом и папкой addons
+type IssueKind int
+
+const (
+	IssueUntracked IssueKind = iota // VPK лежит в addons, но в журнале его нет
+	IssueMissing                    // мод есть в журнале, но его файла нет
+	IssueChanged                    // файл мода изменился после установки
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case IssueUntracked:
+		return "Нет в журнале"
+	case IssueMissing:
+		return "Файл пропал"
+	case IssueChanged:
+		return "Файл изменён"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// Issue — одно расхождение, найденное Reconcile
+type Issue struct {
+	Kind   IssueKind
+	Path   string
+	Mod    InstalledMod // запись журнала, для IssueMissing и IssueChanged
+	SHA256 string       // хеш файла на диске, для IssueUntracked и IssueChanged
+	MD5    string       // MD5 файла на диске, для IssueUntracked
+}
+
+// chunkRe распознаёт части многотомного VPK: name_000.vpk
+var chunkRe = regexp.MustCompile(`(?i)_\d{3}\.vpk$`)
+
+// Reconcile сверяет журнал с папкой addons: ищет VPK, которых нет в журнале, моды,
+// чьи файлы пропали, и файлы, чей хеш не совпадает с записанным при установке.
+// Ничего не меняет. progress вызывается перед проверкой каждого файла и может быть nil.
+func Reconcile(dir string, progress func(done, total int, name string)) ([]Issue, error) {
+	unlock, err := lockLog(dir)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := readLogOrEmpty(dir)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := make(map[string]bool, len(mods))
+	for _, m := range mods {
+		if m.Path != "" {
+			tracked[pathKey(m.Path)] = true
+		}
+	}
+	addonsDir := addons.Dir(dir)
+	var untracked []string
+	entries, err := os.ReadDir(addonsDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || !strings.EqualFold(filepath.Ext(name), ".vpk") || chunkRe.MatchString(name) {
+			continue
+		}
+		path := filepath.Join(addonsDir, name)
+		if !tracked[pathKey(path)] {
+			untracked = append(untracked, path)
+		}
+	}
+
+	total := len(mods) + len(untracked)
+	report := func(done int, name string) {
+		if progress != nil {
+			progress(done, total, name)
+		}
+	}
+
+	var issues []Issue
+	for i, m := range mods {
+		report(i, m.Name)
+		if m.Path == "" {
+			continue
+		}
+		if _, err := os.Stat(m.Path); errors.Is(err, fs.ErrNotExist) {
+			issues = append(issues, Issue{Kind: IssueMissing, Path: m.Path, Mod: m})
+			continue
+		}
+		// Моды из старых журналов без хеша сравнить не с чем
+		if m.SHA256 == "" {
+			continue
+		}
+		hash, err := store.HashFile(m.Path)
+		if err != nil {
+			return nil, err
+		}
+		if hash != m.SHA256 {
+			issues = append(issues, Issue{Kind: IssueChanged, Path: m.Path, Mod: m, SHA256: hash})
+		}
+	}
+	for i, path := range untracked {
+		report(len(mods)+i, filepath.Base(path))
+		sum, md5sum, err := hashFile(path)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, Issue{Kind: IssueUntracked, Path: path, SHA256: sum, MD5: md5sum})
+	}
+	report(total, "")
+	return issues, nil
+}
+
+// pathKey приводит путь к виду, в котором его можно сравнивать с путями из журнала
+func pathKey(path string) string {
+	return strings.ToLower(filepath.Clean(path))
+}
+
+// hashFile считает SHA-256 и MD5 файла за один проход
+func hashFile(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	sha, sum := sha256.New(), md5.New()
+	if _, err := io.Copy(io.MultiWriter(sha, sum), f); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sha.Sum(nil)), hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// Adopt вносит в журнал VPK path, найденный в addons. Название, ID и данные
+// GameBanana берутся из info; при ID 0 мод считается локальным. VPK с именем не
+// из слотов переносится в следующий свободный слот. Копия файла кладётся в
+// хранилище, как при обычной установке.
+func Adopt(path string, info InstalledMod, dir string) (InstalledMod, error) {
+	if err := addons.EnsureGameClosed(); err != nil {
+		return InstalledMod{}, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return InstalledMod{}, err
+	}
+	if base := filepath.Base(path); strings.HasSuffix(strings.ToLower(base), "_dir.vpk") {
+		chunks, _ := filepath.Glob(filepath.Join(filepath.Dir(path), base[:len(base)-len("_dir.vpk")]+"_[0-9][0-9][0-9].vpk"))
+		if len(chunks) > 0 {
+			return InstalledMod{}, fmt.Errorf("%s состоит из нескольких частей, такие VPK не поддерживаются", base)
+		}
+	}
+
+	var adopted InstalledMod
+	err = update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		for _, m := range mods {
+			if pathKey(m.Path) == pathKey(path) {
+				return nil, fmt.Errorf("%s уже есть в журнале", filepath.Base(path))
+			}
+			if info.ID != 0 && m.ID == info.ID {
+				return nil, fmt.Errorf("мод %d уже установлен (%s)", m.ID, m.Name)
+			}
+		}
+
+		mod := info
+		if mod.ID == 0 {
+			mod.ID = nextLocalID(mods)
+		}
+		if mod.Name == "" {
+			mod.Name = filepath.Base(path)
+		}
+		hash, err := store.AddFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось сохранить %s в хранилище: %w", mod.Name, err)
+		}
+		mod.SHA256 = hash
+		mod.Path = path
+		if _, ok := addons.ParseSlot(path); !ok {
+			if mod.Path, err = addons.Install(path, filepath.Dir(path)); err != nil {
+				return nil, err
+			}
+		}
+		if mod.MD5 != "" {
+			if err := store.Remember(mod.MD5, mod.SHA256); err != nil {
+				fmt.Println("Failed to remember download:", err)
+			}
+		}
+		mod.Installed = stat.ModTime()
+		mod.Enabled = true
+		mod.Slot = 0
+		mod.Problem = ""
+		mod.Heroes, _ = addons.Heroes(mod.Path)
+
+		adopted = mod
+		return append(mods, mod), nil
+	})
+	return adopted, err
+}
+
+// Forget убирает мод из журнала, не трогая его файлы
+func Forget(id int, dir string) error {
+	return update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		index := findMod(mods, id)
+		if index < 0 {
+			return nil, fmt.Errorf("мод %d не найден в списке установленных", id)
+		}
+		return append(mods[:index], mods[index+1:]...), nil
+	})
+}
+
+// Reinstall возвращает пропавший или изменённый файл мода из хранилища. Если
+// копии в хранилище нет (или она тоже изменена), возвращает store.ErrNotInStore:
+// такой мод остаётся только скачать заново.
+func Reinstall(id int, dir string) error {
+	if err := addons.EnsureGameClosed(); err != nil {
+		return err
+	}
+	return update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		index := findMod(mods, id)
+		if index < 0 {
+			return nil, fmt.Errorf("мод %d не найден в списке установленных", id)
+		}
+		mod := &mods[index]
+		if err := checkStored(mod.SHA256); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(mod.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err := restoreFromStore(mod); err != nil {
+			return nil, err
+		}
+		mod.Problem = ""
+		return mods, nil
+	})
+}
+
+// checkStored проверяет, что в хранилище лежит неповреждённая копия файла hash.
+// Файл в addons может быть жёсткой ссылкой на копию в хранилище, и тогда правка
+// файла мода меняет и её.
+func checkStored(hash string) error {
+	if hash == "" || !store.Has(hash) {
+		return fmt.Errorf("%w: %s", store.ErrNotInStore, hash)
+	}
+	storePath, err := store.Path(hash)
+	if err != nil {
+		return err
+	}
+	if actual, err := store.HashFile(storePath); err != nil || actual != hash {
+		return fmt.Errorf("%w: копия %s изменена", store.ErrNotInStore, hash)
+	}
+	return nil
+}
+
+// AcceptChange записывает изменённый файл мода как новую версию: кладёт его в
+// хранилище, обновляет хеш и героев. Испорченная старая копия удаляется из хранилища.
+func AcceptChange(id int, dir string) error {
+	return update(dir, func(mods []InstalledMod) ([]InstalledMod, error) {
+		index := findMod(mods, id)
+		if index < 0 {
+			return nil, fmt.Errorf("мод %d не найден в списке установленных", id)
+		}
+		mod := &mods[index]
+		if mod.SHA256 != "" && store.Has(mod.SHA256) && checkStored(mod.SHA256) != nil {
+			if err := store.Remove(mod.SHA256); err != nil {
+				return nil, err
+			}
+		}
+		hash, err := store.AddFile(mod.Path)
+		if err != nil {
+			return nil, err
+		}
+		mod.SHA256 = hash
+		mod.Problem = ""
+		if heroes, err := addons.Heroes(mod.Path); err == nil {
+			mod.Heroes = heroes
+		}
+		return mods, nil
+	})
+}
@@ -0,0 +1,244 @@
+package installlog
+
+import (
+	"DeadlockHelper/internal/fsutil"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Формат installed_mods.json:
+//
+//	версия 0  голый массив InstalledMod (старые версии программы)
+//	версия 1  {"schema": 1, "app_version": "...", "mods": [...]}
+//
+// Журнал старой версии перед переводом в новую копируется рядом
+// в installed_mods.json.v<версия>-<время>.bak.
+
+// SchemaVersion — версия формата журнала, которую пишет эта программа
+const SchemaVersion = 1
+
+// AppVersion записывается в журнал, чтобы было видно, какая программа его сохранила
+var AppVersion = "dev"
+
+var (
+	// ErrCorruptLog — журнал не удалось разобрать. Файл не перезаписывается, пока
+	// его не восстановят через RecoverLog.
+	ErrCorruptLog = errors.New("журнал установленных модов повреждён")
+	// ErrNewerSchema — журнал сохранён более новой версией программы
+	ErrNewerSchema = errors.New("журнал установленных модов сохранён более новой версией программы")
+)
+
+const (
+	backupSuffix  = ".bak"
+	corruptSuffix = ".corrupt"
+	timeLayout    = "20060102-150405"
+)
+
+// document — журнал версии 1
+type document struct {
+	Schema     int            `json:"schema"`
+	AppVersion string         `json:"app_version,omitempty"`
+	Mods       []InstalledMod `json:"mods"`
+}
+
+// migrations[v] переводит содержимое журнала из версии v в версию v+1
+var migrations = []func(data []byte) ([]byte, error){
+	0: migrateV0,
+}
+
+// migrateV0 заворачивает голый массив в документ с версией
+func migrateV0(data []byte) ([]byte, error) {
+	var mods []json.RawMessage
+	if err := json.Unmarshal(data, &mods); err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Schema int               `json:"schema"`
+		Mods   []json.RawMessage `json:"mods"`
+	}{1, mods})
+}
+
+// schemaOf определяет версию формата журнала
+func schemaOf(data []byte) (int, error) {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) == 0:
+		return 0, errors.New("файл пуст")
+	case data[0] == '[':
+		return 0, nil
+	case data[0] == '{':
+		var head struct {
+			Schema int `json:"schema"`
+		}
+		if err := json.Unmarshal(data, &head); err != nil {
+			return 0, err
+		}
+		if head.Schema < 1 {
+			return 0, fmt.Errorf("неизвестная версия %d", head.Schema)
+		}
+		return head.Schema, nil
+	}
+	return 0, errors.New("это не JSON")
+}
+
+// readLog читает журнал, при необходимости переводя его в текущую версию.
+// Отсутствующий файл — ошибка fs.ErrNotExist, повреждённый — ErrCorruptLog.
+// Вызывается под lockLog.
+func readLog(dir string) ([]InstalledMod, error) {
+	path, err := logPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+
+	mods, version, err := decodeLog(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	if version < SchemaVersion {
+		if err := backupLog(path, data, version); err != nil {
+			return nil, fmt.Errorf("не удалось сохранить копию журнала перед обновлением: %w", err)
+		}
+		if err := writeLog(dir, mods); err != nil {
+			return nil, err
+		}
+	}
+	return mods, nil
+}
+
+// decodeLog разбирает журнал любой известной версии и возвращает моды и версию
+func decodeLog(data []byte) ([]InstalledMod, int, error) {
+	version, err := schemaOf(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrCorruptLog, err)
+	}
+	if version > SchemaVersion {
+		return nil, version, fmt.Errorf("%w: версия %d, поддерживается %d", ErrNewerSchema, version, SchemaVersion)
+	}
+
+	current := data
+	for v := version; v < SchemaVersion; v++ {
+		if current, err = migrations[v](current); err != nil {
+			return nil, version, fmt.Errorf("%w: не удалось обновить с версии %d: %v", ErrCorruptLog, v, err)
+		}
+	}
+	var doc document
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return nil, version, fmt.Errorf("%w: %v", ErrCorruptLog, err)
+	}
+	return doc.Mods, version, nil
+}
+
+// readLogOrEmpty читает журнал, считая отсутствующий файл пустым журналом
+func readLogOrEmpty(dir string) ([]InstalledMod, error) {
+	mods, err := readLog(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return mods, err
+}
+
+// writeLog сохраняет журнал в текущей версии формата. Вызывается под lockLog.
+func writeLog(dir string, mods []InstalledMod) error {
+	if mods == nil {
+		mods = []InstalledMod{}
+	}
+	data, err := json.MarshalIndent(document{Schema: SchemaVersion, AppVersion: AppVersion, Mods: mods}, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := logPath(dir)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, data)
+}
+
+// backupLog сохраняет копию журнала версии version рядом с ним, не затирая
+// уже существующие копии
+func backupLog(path string, data []byte, version int) error {
+	base := fmt.Sprintf("%s.v%d-%s", path, version, time.Now().Format(timeLayout))
+	backup := base + backupSuffix
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			backup = fmt.Sprintf("%s.%d%s", base, i, backupSuffix)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// RecoverLog убирает повреждённый журнал в сторону (installed_mods.json.corrupt-<время>)
+// и восстанавливает самую свежую резервную копию, которую удаётся прочитать. Если
+// копий нет, начинается пустой журнал. Возвращает имя восстановленной копии или "".
+func RecoverLog(dir string) (string, error) {
+	unlock, err := lockLog(dir)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	path, err := logPath(dir)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		aside := fmt.Sprintf("%s%s-%s", path, corruptSuffix, time.Now().Format(timeLayout))
+		if err := os.Rename(path, aside); err != nil {
+			return "", fmt.Errorf("не удалось убрать повреждённый журнал: %w", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*" + backupSuffix)
+	// Метка времени сортируется как строка, свежие копии идут последними
+	sort.Slice(backups, func(i, j int) bool {
+		return backupTime(backups[i]) < backupTime(backups[j])
+	})
+	for i := len(backups) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(backups[i])
+		if err != nil {
+			continue
+		}
+		mods, _, err := decodeLog(data)
+		if err != nil {
+			continue
+		}
+		if err := writeLog(dir, mods); err != nil {
+			return "", err
+		}
+		return filepath.Base(backups[i]), nil
+	}
+	return "", writeLog(dir, nil)
+}
+
+// backupTime возвращает метку времени из имени резервной копии вида
+// installed_mods.json.v0-ггггммдд-ччммсс[.N].bak
+func backupTime(name string) string {
+	name = strings.TrimSuffix(filepath.Base(name), backupSuffix)
+	if i := strings.Index(name, ".json.v"); i >= 0 {
+		name = name[i+len(".json.v"):]
+	}
+	if i := strings.Index(name, "-"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,82 @@
+package installlog
+
+import (
+	"DeadlockHelper/internal/fsutil"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Журнал читают и пишут несколько горутин (параллельные загрузки модов) и иногда
+// несколько копий программы. Каждое изменение — чтение, правка и запись — идёт
+// под двумя замками: мьютексом внутри процесса и блокировкой файла
+// installed_mods.json.lock между процессами. Запись идёт во временный файл,
+// который после fsync атомарно заменяет журнал, поэтому падение посреди записи
+// оставляет старый журнал целым.
+
+// logMu сериализует доступ к журналу внутри процесса. Блокировка файла этого
+// не даёт: flock на разных дескрипторах одного процесса тоже конфликтует,
+// и вторая горутина ждала бы вечно.
+var logMu sync.Mutex
+
+const lockSuffix = ".lock"
+
+// lockLog берёт оба замка журнала установки dir и возвращает функцию, которая их
+// снимает. Под замком же журнал переносится из папки игры, если он ещё там.
+func lockLog(dir string) (func(), error) {
+	path, err := logPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	logMu.Lock()
+	f, err := os.OpenFile(path+lockSuffix, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		logMu.Unlock()
+		return nil, fmt.Errorf("не удалось открыть блокировку журнала: %w", err)
+	}
+	if err := fsutil.LockFile(f); err != nil {
+		f.Close()
+		logMu.Unlock()
+		return nil, fmt.Errorf("не удалось заблокировать журнал: %w", err)
+	}
+	unlock := func() {
+		fsutil.UnlockFile(f)
+		f.Close()
+		logMu.Unlock()
+	}
+	if err := migrateLegacy(dir, path); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// update читает журнал, передаёт моды в fn и сохраняет то, что она вернула, не
+// отпуская замков. Все изменения журнала идут через update: иначе правка, сделанная
+// между чтением и записью, потеряется. Отсутствующий журнал считается пустым.
+//
+// Если fn вернула ошибку и nil, журнал не меняется. Если вместе с ошибкой вернулись
+// моды (файлы на диске уже успели измениться), они сохраняются, а ошибка всё равно
+// возвращается.
+func update(dir string, fn func(mods []InstalledMod) ([]InstalledMod, error)) error {
+	unlock, err := lockLog(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	mods, err := readLogOrEmpty(dir)
+	if err != nil {
+		return err
+	}
+	mods, err = fn(mods)
+	if err != nil {
+		if mods != nil {
+			if werr := writeLog(dir, mods); werr != nil {
+				return fmt.Errorf("%w (журнал тоже не сохранён: %v)", err, werr)
+			}
+		}
+		return err
+	}
+	return writeLog(dir, mods)
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package fsutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// LockFile берёт эксклюзивную рекомендательную блокировку файла, ожидая её освобождения
+func LockFile(f *os.File) error {
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+// UnlockFile снимает блокировку, взятую LockFile
+func UnlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
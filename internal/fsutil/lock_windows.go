@@ -0,0 +1,19 @@
+package fsutil
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// LockFile берёт эксклюзивную блокировку первого байта файла, ожидая её освобождения
+func LockFile(f *os.File) error {
+	var overlapped windows.Overlapped
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &overlapped)
+}
+
+// UnlockFile снимает блокировку, взятую LockFile
+func UnlockFile(f *os.File) error {
+	var overlapped windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &overlapped)
+}
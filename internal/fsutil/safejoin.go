@@ -0,0 +1,35 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath возвращается для путей из архива, которые пытаются выйти за пределы
+// папки распаковки
+var ErrUnsafePath = errors.New("unsafe path in archive")
+
+// SafeJoin соединяет dstDir и путь из архива (ZIP, RAR, VPK и других), отклоняя
+// абсолютные пути, выход через "..", имена с буквой диска и зарезервированные
+// имена устройств Windows. Разделителями считаются и "/", и "\".
+func SafeJoin(dstDir, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if slashed == "" || path.IsAbs(slashed) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+	cleaned := path.Clean(slashed)
+	local := filepath.FromSlash(cleaned)
+	if !filepath.IsLocal(local) || strings.Contains(cleaned, ":") {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+
+	outPath := filepath.Join(dstDir, local)
+	rel, err := filepath.Rel(dstDir, outPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+	return outPath, nil
+}
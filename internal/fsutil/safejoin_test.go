@@ -0,0 +1,49 @@
+package fsutil
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	dst := t.TempDir()
+	tests := []struct {
+		name string
+		want string // пусто — путь должен быть отклонён
+	}{
+		{"pak01_dir.vpk", "pak01_dir.vpk"},
+		{"mod/pak01_dir.vpk", filepath.Join("mod", "pak01_dir.vpk")},
+		{"mod\\pak01_dir.vpk", filepath.Join("mod", "pak01_dir.vpk")},
+		{"mod/../pak01_dir.vpk", "pak01_dir.vpk"},
+		{"./pak01_dir.vpk", "pak01_dir.vpk"},
+		{"", ""},
+		{"..", ""},
+		{"../evil.vpk", ""},
+		{"mod/../../evil.vpk", ""},
+		{"..\\evil.vpk", ""},
+		{"/etc/passwd", ""},
+		{"\\evil.vpk", ""},
+		{"C:\\evil.vpk", ""},
+		{"C:evil.vpk", ""},
+		{"mod/C:evil.vpk", ""},
+	}
+	for _, tt := range tests {
+		got, err := SafeJoin(dst, tt.name)
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("SafeJoin(%q) = %q, %v; want %v", tt.name, got, err, ErrUnsafePath)
+			}
+			continue
+		}
+		if want := filepath.Join(dst, tt.want); err != nil || got != want {
+			t.Errorf("SafeJoin(%q) = %q, %v; want %q", tt.name, got, err, want)
+		}
+	}
+	if runtime.GOOS == "windows" {
+		if _, err := SafeJoin(dst, "NUL"); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("SafeJoin(NUL) error = %v, want %v", err, ErrUnsafePath)
+		}
+	}
+}
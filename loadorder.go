@@ -0,0 +1,124 @@
+package main
+
+import (
+	installlog "DeadlockHelper/installedmods"
+	"fmt"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// dragHandle — значок «≡», за который строку списка можно перетащить на другое место
+type dragHandle struct {
+	widget.Label
+	row    fyne.CanvasObject
+	dy     float32
+	onDrop func(shift int)
+}
+
+func newDragHandle() *dragHandle {
+	h := &dragHandle{}
+	h.ExtendBaseWidget(h)
+	h.SetText("≡")
+	return h
+}
+
+func (h *dragHandle) Dragged(e *fyne.DragEvent) {
+	h.dy += e.Dragged.DY
+}
+
+func (h *dragHandle) DragEnd() {
+	rowHeight := h.MinSize().Height
+	if h.row != nil {
+		rowHeight = h.row.Size().Height
+	}
+	rowHeight += theme.Padding()
+	shift := int(math.Round(float64(h.dy / rowHeight)))
+	h.dy = 0
+	if shift != 0 && h.onDrop != nil {
+		h.onDrop(shift)
+	}
+}
+
+// showLoadOrderWindow показывает порядок загрузки установленных модов. Мод выше в списке
+// получает меньший номер pak и побеждает, если несколько модов меняют один и тот же файл.
+func showLoadOrderWindow(a fyne.App, parent fyne.Window, dir string, onApplied func()) {
+	mods, err := installlog.LoadInstalledMods(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось загрузить установленные моды: %w", err), parent)
+		return
+	}
+	installlog.SortByLoadOrder(mods)
+
+	window := a.NewWindow("Порядок загрузки")
+	window.Resize(fyne.NewSize(500, 600))
+
+	var list *widget.List
+	move := func(from, to int) {
+		if to < 0 {
+			to = 0
+		}
+		if to > len(mods)-1 {
+			to = len(mods) - 1
+		}
+		if from == to {
+			return
+		}
+		mod := mods[from]
+		mods = append(mods[:from], mods[from+1:]...)
+		mods = append(mods[:to], append([]installlog.InstalledMod{mod}, mods[to:]...)...)
+		list.Refresh()
+	}
+
+	list = widget.NewList(
+		func() int { return len(mods) },
+		func() fyne.CanvasObject {
+			handle := newDragHandle()
+			row := container.NewHBox(
+				handle,
+				widget.NewLabel(""),
+				layout.NewSpacer(),
+				widget.NewButtonWithIcon("", theme.MoveUpIcon(), nil),
+				widget.NewButtonWithIcon("", theme.MoveDownIcon(), nil),
+			)
+			handle.row = row
+			return row
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*dragHandle).onDrop = func(shift int) { move(id, id+shift) }
+			text := fmt.Sprintf("%d. %s", id+1, mods[id].Name)
+			if !mods[id].Enabled {
+				text += " (выключен)"
+			}
+			row.Objects[1].(*widget.Label).SetText(text)
+			row.Objects[3].(*widget.Button).OnTapped = func() { move(id, id-1) }
+			row.Objects[4].(*widget.Button).OnTapped = func() { move(id, id+1) }
+		},
+	)
+
+	applyBtn := widget.NewButton("Применить порядок", func() {
+		updated, err := installlog.ApplyLoadOrder(mods, dir)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		mods = updated
+		list.Refresh()
+		dialog.ShowInformation("Готово", "Порядок загрузки применён", window)
+		if onApplied != nil {
+			onApplied()
+		}
+	})
+
+	hint := widget.NewLabel("Перетащите мод за «≡» или используйте стрелки. Моды выше в списке имеют приоритет.")
+	hint.Wrapping = fyne.TextWrapWord
+
+	window.SetContent(container.NewBorder(hint, applyBtn, nil, nil, list))
+	window.Show()
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	addons "DeadlockHelper/Addons"
 	config "DeadlockHelper/Config"
 	extractfile "DeadlockHelper/ExtractFile"
 	gamebanana "DeadlockHelper/Parser"
 	updater "DeadlockHelper/SearchPath"
+	store "DeadlockHelper/Store"
 	installlog "DeadlockHelper/installedmods"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"io/fs"
 	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -21,29 +25,15 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
-func searchMods(query string, gameID int) ([]gamebanana.Mod, error) {
-	api := fmt.Sprintf(
-		"https://gamebanana.com/apiv11/Util/Search/Results?_sSearchString=%s&_idGameRow=%d",
-		url.QueryEscape(query), gameID)
-	resp, err := http.Get(api)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// version задаётся при сборке: go build -ldflags "-X main.version=1.2.0"
+var version = "dev"
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search request failed: %s", resp.Status)
-	}
-	var out struct {
-		ARecords []gamebanana.Mod `json:"_aRecords"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, err
+func main() {
+	installlog.AppVersion = version
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
 	}
-	return out.ARecords, nil
-}
 
-func main() {
 	a := app.New()
 	w := a.NewWindow("Deadlock Helper")
 	w.Resize(fyne.NewSize(600, 400))
@@ -74,7 +64,13 @@ func main() {
 			dialog.ShowError(fmt.Errorf("путь не может быть пустым"), w)
 			return
 		}
-		err := config.SaveConfig(config.Config{DeadlockPath: path})
+		current, err := config.LoadConfig()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка загрузки конфига: %w", err), w)
+			return
+		}
+		current.DeadlockPath = path
+		err = config.SaveConfig(current)
 		if err != nil {
 			dialog.ShowError(fmt.Errorf("ошибка сохранения конфига: %w", err), w)
 			return
@@ -115,11 +111,15 @@ func main() {
 		showInstalledModsWindow(a, w, rootInput.Text)
 	})
 
+	openVPKBtn := widget.NewButton("Открыть VPK или архив", func() {
+		showOpenVPKDialog(a, w)
+	})
+
 	w.SetContent(container.NewVBox(
 		statusLabel,
 		rootInput,
 		savePathBtn,
-		container.NewHBox(loadBtn, updateBtn, installedBtn),
+		container.NewHBox(loadBtn, updateBtn, installedBtn, openVPKBtn),
 	))
 
 	w.ShowAndRun()
@@ -132,16 +132,26 @@ func showInstalledModsWindow(a fyne.App, parent fyne.Window, dir string) {
 	}
 
 	mods, err := installlog.LoadInstalledMods(dir)
+	if errors.Is(err, installlog.ErrCorruptLog) {
+		askRecoverLog(err, dir, parent, func() {
+			showInstalledModsWindow(a, parent, dir)
+		})
+		return
+	}
 	if err != nil {
 		dialog.ShowError(fmt.Errorf("не удалось загрузить установленные моды: %w", err), parent)
 		return
 	}
+	installlog.SortByLoadOrder(mods)
 
 	window := a.NewWindow("Установленные моды")
 	window.Resize(fyne.NewSize(800, 600))
 
-	grid := container.NewGridWithColumns(3)
-	for _, mod := range mods {
+	if detected, err := installlog.DetectHeroes(mods, dir); err == nil {
+		mods = detected
+	}
+
+	newCard := func(mod installlog.InstalledMod) fyne.CanvasObject {
 		modCopy := mod
 		var img fyne.CanvasObject = widget.NewLabel("Нет изображения")
 		if mod.ImageURL != "" {
@@ -151,11 +161,46 @@ func showInstalledModsWindow(a fyne.App, parent fyne.Window, dir string) {
 				image.SetMinSize(fyne.NewSize(150, 150))
 				img = image
 			}
+		} else {
+			// У локальных модов картинки нет, показываем их текстуры
+			img = previewThumbnail(mod)
+		}
+
+		enabledCheck := widget.NewCheck("Включён", nil)
+		enabledCheck.SetChecked(mod.Enabled)
+		enabledCheck.OnChanged = func(on bool) {
+			if err := installlog.SetModEnabled(modCopy.ID, on, dir); err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось переключить мод: %w", err), window)
+				enabledCheck.SetChecked(!on)
+			}
 		}
 
 		card := container.NewVBox(
 			img,
 			widget.NewLabel(mod.Name),
+		)
+		if mod.Problem != "" {
+			problem := widget.NewLabelWithStyle("⚠ VPK повреждён", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			problem.Importance = widget.DangerImportance
+			card.Add(problem)
+			card.Add(widget.NewButton("Подробнее", func() {
+				dialog.ShowInformation(modCopy.Name, modCopy.Problem, window)
+			}))
+		}
+		card.Add(widget.NewButton("Сведения и звуки", func() {
+			showModDetails(a, modCopy)
+		}))
+		if mod.IsComposite() {
+			card.Add(widget.NewLabel(fmt.Sprintf("Составной: %d модов", len(mod.Sources))))
+			card.Add(widget.NewButton("Разделить", func() {
+				splitComposite(window, modCopy, dir, func() {
+					window.Close()
+					showInstalledModsWindow(a, parent, dir)
+				})
+			}))
+		}
+		card.Add(enabledCheck)
+		card.Add(
 			widget.NewButton("Удалить", func() {
 				confirm := dialog.NewConfirm("Удалить мод", "Вы уверены?", func(confirmed bool) {
 					if !confirmed {
@@ -170,14 +215,93 @@ func showInstalledModsWindow(a fyne.App, parent fyne.Window, dir string) {
 					showInstalledModsWindow(a, parent, dir)
 				}, window)
 				confirm.Show()
-			}),
-		)
+			}))
+		return container.NewBorder(nil, nil, nil, nil, card)
+	}
 
-		grid.Add(container.NewBorder(nil, nil, nil, nil, card))
+	grid := container.NewGridWithColumns(3)
+	for _, mod := range mods {
+		grid.Add(newCard(mod))
 	}
 
 	scroll := container.NewVScroll(grid)
-	window.SetContent(scroll)
+	groupCheck := widget.NewCheck("По героям", func(on bool) {
+		a.Preferences().SetBool("group_by_hero", on)
+		if on {
+			scroll.Content = groupByHero(mods, newCard)
+		} else {
+			scroll.Content = grid
+		}
+		scroll.Refresh()
+	})
+	groupCheck.SetChecked(a.Preferences().Bool("group_by_hero"))
+
+	loadOrderBtn := widget.NewButton("Порядок загрузки", func() {
+		showLoadOrderWindow(a, window, dir, func() {
+			window.Close()
+			showInstalledModsWindow(a, parent, dir)
+		})
+	})
+
+	profileBar := newProfileBar(window, dir, func() {
+		window.Close()
+		showInstalledModsWindow(a, parent, dir)
+	})
+
+	modpackBar := newModpackBar(a, window, dir, func() {
+		window.Close()
+		showInstalledModsWindow(a, parent, dir)
+	})
+
+	verifyBtn := widget.NewButton("Проверить моды", func() {
+		verifyMods(window, dir, func() {
+			window.Close()
+			showInstalledModsWindow(a, parent, dir)
+		})
+	})
+
+	reconcileBtn := widget.NewButton("Сверить с addons", func() {
+		reconcileMods(a, window, dir, func() {
+			window.Close()
+			showInstalledModsWindow(a, parent, dir)
+		})
+	})
+
+	createBtn := widget.NewButton("Создать мод из папки", func() {
+		showCreateModDialog(window, dir, func() {
+			window.Close()
+			showInstalledModsWindow(a, parent, dir)
+		})
+	})
+
+	mergeBtn := widget.NewButton("Объединить моды", func() {
+		showMergeDialog(window, mods, dir, func() {
+			window.Close()
+			showInstalledModsWindow(a, parent, dir)
+		})
+	})
+
+	conflictsBtn := widget.NewButton("Конфликты", func() {
+		showConflictsWindow(a, window, dir)
+	})
+
+	pruneBtn := widget.NewButton("Очистить хранилище", func() {
+		// Файлы установленных и выключенных модов (и модов внутри составных) всех
+		// установок игры остаются, удаляются только VPK модов, которые уже удалены
+		keep, err := installlog.StoreHashes()
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("не удалось прочитать журналы установок: %w", err), window)
+			return
+		}
+		freed, err := store.Prune(keep)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("не удалось очистить хранилище: %w", err), window)
+			return
+		}
+		dialog.ShowInformation("Хранилище", fmt.Sprintf("Освобождено %.1f МБ", float64(freed)/(1<<20)), window)
+	})
+
+	window.SetContent(container.NewBorder(container.NewVBox(profileBar, container.NewHBox(createBtn, mergeBtn, loadOrderBtn, conflictsBtn, verifyBtn, reconcileBtn, modpackBar, pruneBtn, groupCheck)), nil, nil, nil, scroll))
 	window.Show()
 }
 
@@ -256,7 +380,7 @@ func showModsWindow(a fyne.App, parent fyne.Window, initial []gamebanana.Mod, sa
 		loadingDialog.Show()
 
 		go func() {
-			mods, err := searchMods(query, 20948)
+			mods, err := gamebanana.SearchMods(query, gamebanana.GameID)
 			fyne.Do(func() {
 				loadingDialog.Hide()
 				if err != nil {
@@ -314,35 +438,254 @@ func downloadMod(mod gamebanana.Mod, dir string, parent fyne.Window) {
 	progress.Show()
 
 	go func() {
-		outPath, err := gamebanana.DownloadModToDir(mod.ID, dir)
+		files, err := gamebanana.FetchModFiles(mod.ID)
 		if err != nil {
 			fyne.Do(func() {
 				progress.Hide()
-				dialog.ShowError(fmt.Errorf("не удалось скачать: %w", err), parent)
+				dialog.ShowError(fmt.Errorf("не удалось получить данные мода: %w", err), parent)
 			})
 			return
 		}
+		file, err := gamebanana.PickFile(files, mod.ID, 0)
+		if err != nil {
+			fyne.Do(func() {
+				progress.Hide()
+				dialog.ShowError(fmt.Errorf("не удалось получить данные мода: %w", err), parent)
+			})
+			return
+		}
+
+		// Тот же файл уже скачивался — ставим его из хранилища без загрузки
+		if hash, ok := store.Lookup(file.MD5); ok {
+			if storePath, err := store.Path(hash); err == nil {
+				staged := &extractfile.Staged{Path: storePath, SHA256: hash}
+				installStaged(mod, staged, gamebanana.Download{FileID: file.ID, MD5: file.MD5}, dir, progress, parent)
+				return
+			}
+		}
 
-		modPath, err := extractfile.ExtractAndInstallVPK(outPath, dir)
+		download, err := gamebanana.DownloadFile(files, file, dir)
 		if err != nil {
 			fyne.Do(func() {
 				progress.Hide()
-				dialog.ShowError(fmt.Errorf("не удалось установить мод: %w", err), parent)
+				dialog.ShowError(fmt.Errorf("не удалось скачать: %w", err), parent)
 			})
 			return
 		}
 
-		_ = installlog.SaveInstalledMod(installlog.InstalledMod{
-			ID:        mod.ID,
-			Name:      mod.Name,
-			ImageURL:  mod.ImageURL(),
-			Path:      modPath,
-			Installed: time.Now(),
-		}, dir)
+		installArchive(mod, download, dir, "", progress, parent)
+	}()
+}
+
+// installArchive распаковывает скачанный архив мода и записывает его в installlog.
+// Если архив зашифрован, спрашивает пароль и повторяет установку с ним.
+func installArchive(mod gamebanana.Mod, download gamebanana.Download, dir, password string, progress *dialog.ProgressInfiniteDialog, parent fyne.Window) {
+	staged, err := extractfile.Stage(download.Path, password)
+	var pwErr *extractfile.PasswordError
+	if errors.As(err, &pwErr) {
+		fyne.Do(func() {
+			progress.Hide()
+			askArchivePassword(mod.Name, pwErr.Wrong, parent, func(pw string) {
+				progress.Show()
+				go installArchive(mod, download, dir, pw, progress, parent)
+			})
+		})
+		return
+	}
+	if err != nil {
+		fyne.Do(func() {
+			progress.Hide()
+			dialog.ShowError(fmt.Errorf("не удалось установить мод: %w", err), parent)
+		})
+		return
+	}
+
+	// Запоминаем сразу: даже если установку отменят, второй раз качать не придётся
+	_ = store.Remember(download.MD5, staged.SHA256)
+
+	installStaged(mod, staged, download, dir, progress, parent)
+}
+
+// installStaged проверяет, какие файлы извлечённого VPK уже подменяют установленные
+// моды, и при пересечении спрашивает, ставить новый мод выше или ниже них. До ответа
+// в addons ничего не копируется.
+func installStaged(mod gamebanana.Mod, staged *extractfile.Staged, download gamebanana.Download, dir string, progress *dialog.ProgressInfiniteDialog, parent fyne.Window) {
+	heroes, err := addons.Heroes(staged.Path)
+	if err != nil {
+		fmt.Println("Failed to detect heroes:", err)
+	}
+	overlaps, err := addons.Overlaps(staged.Path, addons.Dir(dir))
+	if err != nil {
+		fmt.Println("Failed to check conflicts:", err)
+	}
+	if len(overlaps) == 0 {
+		finishInstall(mod, staged, download, heroes, dir, nil, false, progress, parent)
+		return
+	}
+
+	fyne.Do(func() {
+		progress.Hide()
+		askConflictPlacement(mod.Name, overlaps, dir, parent, func(above, ok bool) {
+			if !ok {
+				staged.Discard()
+				return
+			}
+			progress.Show()
+			go finishInstall(mod, staged, download, heroes, dir, overlaps, above, progress, parent)
+		})
+	})
+}
+
+// finishInstall ставит VPK в addons, записывает его в installlog и, если были
+// пересечения, ставит новый мод выше или ниже пересекающихся модов. Если для тех же
+// героев уже включены другие скины, предлагает их выключить.
+func finishInstall(mod gamebanana.Mod, staged *extractfile.Staged, download gamebanana.Download, heroes []string, dir string, overlaps []addons.Overlap, above bool, progress *dialog.ProgressInfiniteDialog, parent fyne.Window) {
+	defer staged.Discard()
+	installed, err := staged.Install(dir)
+	if err != nil {
+		fyne.Do(func() {
+			progress.Hide()
+			dialog.ShowError(fmt.Errorf("не удалось установить мод: %w", err), parent)
+		})
+		return
+	}
 
+	err = installlog.SaveInstalledMod(installlog.InstalledMod{
+		ID:        mod.ID,
+		Name:      mod.Name,
+		ImageURL:  mod.ImageURL(),
+		Path:      installed.Path,
+		Installed: time.Now(),
+		Enabled:   true,
+		FileID:    download.FileID,
+		MD5:       download.MD5,
+		SHA256:    installed.SHA256,
+		Heroes:    heroes,
+	}, dir)
+	if err != nil {
+		// Мод, которого нет в журнале, не удалить и не переупорядочить из программы,
+		// поэтому убираем его из addons. Копия в хранилище остаётся для повторной установки.
+		err = fmt.Errorf("мод %s не установлен: %w", mod.Name, err)
+		if rmErr := os.Remove(installed.Path); rmErr != nil && !errors.Is(rmErr, fs.ErrNotExist) {
+			err = fmt.Errorf("%w (файл %s остался в addons: %v)", err, installed.Path, rmErr)
+		}
 		fyne.Do(func() {
 			progress.Hide()
+			if errors.Is(err, installlog.ErrCorruptLog) {
+				askRecoverLog(err, dir, parent, func() {
+					dialog.ShowInformation("Журнал восстановлен",
+						fmt.Sprintf("Установите мод %s заново", mod.Name), parent)
+				})
+				return
+			}
+			dialog.ShowError(err, parent)
+		})
+		return
+	}
+
+	if len(overlaps) > 0 {
+		if err := installlog.PlaceMod(mod.ID, overlappingMods(overlaps, dir), above, dir); err != nil {
+			fyne.Do(func() {
+				progress.Hide()
+				dialog.ShowError(fmt.Errorf("мод установлен, но порядок загрузки не изменён: %w", err), parent)
+			})
+			return
+		}
+	}
+
+	skins, _ := installlog.ActiveSkins(heroes, mod.ID, dir)
+	fyne.Do(func() {
+		progress.Hide()
+		if len(skins) == 0 {
 			dialog.ShowInformation("Успех", fmt.Sprintf("Мод %s установлен успешно", mod.Name), parent)
+			return
+		}
+		askDisableSkins(mod.Name, heroes, skins, dir, parent)
+	})
+}
+
+// verifyMods проверяет VPK всех установленных модов в фоне и показывает итог.
+// onDone вызывается после проверки, чтобы окно показало отметки о повреждённых модах.
+func verifyMods(parent fyne.Window, dir string, onDone func()) {
+	status := widget.NewLabel("Подготовка…")
+	bar := widget.NewProgressBar()
+	progress := dialog.NewCustomWithoutButtons("Проверка модов", container.NewVBox(status, bar), parent)
+	progress.Show()
+
+	go func() {
+		failed, err := installlog.VerifyMods(dir, func(done, total int, name string) {
+			fyne.Do(func() {
+				if total > 0 {
+					bar.SetValue(float64(done) / float64(total))
+				}
+				if name != "" {
+					status.SetText(fmt.Sprintf("Проверка %d из %d: %s", done+1, total, name))
+				}
+			})
+		})
+		fyne.Do(func() {
+			progress.Hide()
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось проверить моды: %w", err), parent)
+				return
+			}
+			message := "Все моды в порядке"
+			if len(failed) > 0 {
+				var names []string
+				for _, m := range failed {
+					names = append(names, m.Name)
+				}
+				message = "Повреждённые моды, переустановите их:\n" + strings.Join(names, "\n")
+			}
+			result := dialog.NewInformation("Проверка модов", message, parent)
+			result.SetOnClosed(onDone)
+			result.Show()
 		})
 	}()
 }
+
+// askRecoverLog сообщает о повреждённом журнале установленных модов и предлагает
+// восстановить его из резервной копии. Повреждённый файл при этом не удаляется.
+func askRecoverLog(loadErr error, dir string, parent fyne.Window, onRecovered func()) {
+	message := widget.NewLabel(fmt.Sprintf("%v\n\nМожно восстановить журнал из последней резервной копии "+
+		"(или начать пустой журнал, если копий нет). Повреждённый файл останется рядом "+
+		"с расширением .corrupt, установленные моды в addons не удаляются.", loadErr))
+	message.Wrapping = fyne.TextWrapWord
+	confirm := dialog.NewCustomConfirm("Журнал повреждён", "Восстановить", "Отмена", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		backup, err := installlog.RecoverLog(dir)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("не удалось восстановить журнал: %w", err), parent)
+			return
+		}
+		result := "Резервных копий нет, начат пустой журнал"
+		if backup != "" {
+			result = "Журнал восстановлен из " + backup
+		}
+		done := dialog.NewInformation("Журнал восстановлен", result, parent)
+		done.SetOnClosed(onRecovered)
+		done.Show()
+	}, parent)
+	confirm.Resize(fyne.NewSize(500, 250))
+	confirm.Show()
+}
+
+// askArchivePassword показывает окно ввода пароля к архиву мода
+func askArchivePassword(modName string, wrong bool, parent fyne.Window, onSubmit func(password string)) {
+	passwordInput := widget.NewPasswordEntry()
+	hint := fmt.Sprintf("Архив мода %s защищён паролем", modName)
+	if wrong {
+		hint = "Неверный пароль, попробуйте ещё раз"
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("", widget.NewLabel(hint)),
+		widget.NewFormItem("Пароль", passwordInput),
+	}
+	dialog.ShowForm("Введите пароль", "Распаковать", "Отмена", items, func(ok bool) {
+		if ok && passwordInput.Text != "" {
+			onSubmit(passwordInput.Text)
+		}
+	}, parent)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	installlog "DeadlockHelper/installedmods"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showMergeDialog предлагает выбрать включённые моды и объединить их в один pak
+func showMergeDialog(window fyne.Window, mods []installlog.InstalledMod, dir string, onMerged func()) {
+	var candidates []installlog.InstalledMod
+	for _, m := range mods {
+		if m.Enabled {
+			candidates = append(candidates, m)
+		}
+	}
+	if len(candidates) < 2 {
+		dialog.ShowInformation("Объединить моды", "Для объединения нужно хотя бы два включённых мода", window)
+		return
+	}
+
+	selected := make(map[int]bool)
+	checks := container.NewVBox()
+	for _, m := range candidates {
+		id := m.ID
+		checks.Add(widget.NewCheck(m.Name, func(on bool) {
+			selected[id] = on
+		}))
+	}
+	scroll := container.NewVScroll(checks)
+	scroll.SetMinSize(fyne.NewSize(400, 250))
+
+	nameInput := widget.NewEntry()
+	nameInput.SetText("Сборка модов")
+	items := []*widget.FormItem{
+		widget.NewFormItem("Название", nameInput),
+		widget.NewFormItem("Моды", scroll),
+	}
+	items[1].HintText = "Совпадающие файлы берутся из мода, который выше в порядке загрузки"
+
+	dialog.ShowForm("Объединить моды", "Объединить", "Отмена", items, func(ok bool) {
+		if !ok || nameInput.Text == "" {
+			return
+		}
+		// Порядок id не важен: Merge сам раскладывает моды по порядку загрузки
+		var ids []int
+		for _, m := range candidates {
+			if selected[m.ID] {
+				ids = append(ids, m.ID)
+			}
+		}
+		progress := dialog.NewProgressInfinite("Объединение модов", nameInput.Text, window)
+		progress.Show()
+		go func() {
+			composite, err := installlog.Merge(ids, nameInput.Text, dir)
+			fyne.Do(func() {
+				progress.Hide()
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("не удалось объединить моды: %w", err), window)
+					return
+				}
+				done := dialog.NewInformation("Готово",
+					fmt.Sprintf("Мод %s собран из %d модов", composite.Name, len(composite.Sources)), window)
+				done.SetOnClosed(onMerged)
+				done.Show()
+			})
+		}()
+	}, window)
+}
+
+// splitComposite разделяет составной мод обратно на исходные после подтверждения
+func splitComposite(window fyne.Window, mod installlog.InstalledMod, dir string, onSplit func()) {
+	message := fmt.Sprintf("Вернуть %d модов, из которых собран %s?", len(mod.Sources), mod.Name)
+	dialog.ShowConfirm("Разделить мод", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		progress := dialog.NewProgressInfinite("Разделение мода", mod.Name, window)
+		progress.Show()
+		go func() {
+			err := installlog.Split(mod.ID, dir)
+			fyne.Do(func() {
+				progress.Hide()
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("не удалось разделить мод: %w", err), window)
+					return
+				}
+				onSplit()
+			})
+		}()
+	}, window)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	addons "DeadlockHelper/Addons"
+	modpack "DeadlockHelper/Modpack"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newModpackBar создаёт кнопки экспорта и импорта набора модов для окна установленных
+// модов. onImported вызывается после импорта, чтобы окно перечитало список.
+func newModpackBar(a fyne.App, window fyne.Window, dir string, onImported func()) fyne.CanvasObject {
+	exportBtn := widget.NewButton("Экспорт набора", func() {
+		showModpackExport(a, window, dir)
+	})
+	importBtn := widget.NewButton("Импорт набора", func() {
+		showModpackImport(window, dir, onImported)
+	})
+	return container.NewHBox(exportBtn, importBtn)
+}
+
+// showModpackExport показывает код набора и предлагает сохранить набор в файл
+func showModpackExport(a fyne.App, window fyne.Window, dir string) {
+	manifest, err := modpack.Export(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось прочитать установленные моды: %w", err), window)
+		return
+	}
+	code, err := manifest.Code()
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось создать код набора: %w", err), window)
+		return
+	}
+
+	codeEntry := widget.NewMultiLineEntry()
+	codeEntry.SetText(code)
+	codeEntry.Wrapping = fyne.TextWrapBreak
+	codeEntry.SetMinRowsVisible(4)
+
+	copyBtn := widget.NewButton("Копировать код", func() {
+		a.Clipboard().SetContent(code)
+	})
+	saveBtn := widget.NewButton("Сохранить в файл…", func() {
+		save := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if w == nil {
+				return
+			}
+			defer w.Close()
+			data, err := manifest.Encode()
+			if err == nil {
+				_, err = w.Write(data)
+			}
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось сохранить набор: %w", err), window)
+			}
+		}, window)
+		save.SetFileName("modpack.json")
+		save.Show()
+	})
+
+	hint := widget.NewLabel(fmt.Sprintf("Модов в наборе: %d. Отправьте код или файл другу.", len(manifest.Mods)))
+	content := container.NewVBox(hint, codeEntry, container.NewHBox(copyBtn, saveBtn))
+	d := dialog.NewCustom("Экспорт набора", "Закрыть", content, window)
+	d.Resize(fyne.NewSize(500, 300))
+	d.Show()
+}
+
+// showModpackImport принимает код или файл набора, показывает, что изменится, и после
+// подтверждения скачивает недостающие моды и применяет порядок набора
+func showModpackImport(window fyne.Window, dir string, onImported func()) {
+	codeEntry := widget.NewMultiLineEntry()
+	codeEntry.SetPlaceHolder("Вставьте код набора")
+	codeEntry.Wrapping = fyne.TextWrapBreak
+	codeEntry.SetMinRowsVisible(4)
+
+	openBtn := widget.NewButton("Открыть файл…", func() {
+		dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			if r == nil {
+				return
+			}
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось прочитать файл: %w", err), window)
+				return
+			}
+			codeEntry.SetText(string(data))
+		}, window)
+	})
+
+	content := container.NewVBox(codeEntry, openBtn)
+	d := dialog.NewCustomConfirm("Импорт набора", "Далее", "Отмена", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		manifest, err := modpack.Parse([]byte(codeEntry.Text))
+		if errors.Is(err, modpack.ErrUnsupportedVersion) {
+			dialog.ShowError(fmt.Errorf("набор создан более новой версией программы, обновите её"), window)
+			return
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("не удалось прочитать набор: %w", err), window)
+			return
+		}
+		confirmModpackImport(manifest, window, dir, onImported)
+	}, window)
+	d.Resize(fyne.NewSize(500, 300))
+	d.Show()
+}
+
+// confirmModpackImport показывает разницу между набором и установленными модами
+func confirmModpackImport(manifest modpack.Manifest, window fyne.Window, dir string, onImported func()) {
+	plan, err := modpack.PlanImport(manifest, dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("не удалось прочитать установленные моды: %w", err), window)
+		return
+	}
+
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Уже установлено: %d", len(plan.Present)))
+	lines = append(lines, fmt.Sprintf("Будет скачано: %d", len(plan.Missing)))
+	for _, m := range plan.Missing {
+		lines = append(lines, "  + "+m.Name)
+	}
+	if len(plan.Extra) > 0 {
+		lines = append(lines, fmt.Sprintf("Будет выключено: %d", len(plan.Extra)))
+		for _, m := range plan.Extra {
+			lines = append(lines, "  − "+m.Name)
+		}
+	}
+	if len(plan.Unresolved) > 0 {
+		lines = append(lines, fmt.Sprintf("Локальные моды автора, их не скачать: %d", len(plan.Unresolved)))
+		for _, m := range plan.Unresolved {
+			lines = append(lines, "  ? "+m.Name)
+		}
+	}
+
+	summary := widget.NewLabel(strings.Join(lines, "\n"))
+	d := dialog.NewCustomConfirm("Импорт набора", "Импортировать", "Отмена", container.NewVScroll(summary), func(ok bool) {
+		if ok {
+			runModpackImport(manifest, window, dir, onImported)
+		}
+	}, window)
+	d.Resize(fyne.NewSize(500, 400))
+	d.Show()
+}
+
+// runModpackImport импортирует набор в фоне и показывает итог
+func runModpackImport(manifest modpack.Manifest, window fyne.Window, dir string, onImported func()) {
+	status := widget.NewLabel("Подготовка…")
+	bar := widget.NewProgressBar()
+	progress := dialog.NewCustomWithoutButtons("Импорт набора", container.NewVBox(status, bar), window)
+	progress.Show()
+
+	go func() {
+		result, err := modpack.Import(manifest, dir, func(done, total int, name string) {
+			fyne.Do(func() {
+				if total > 0 {
+					bar.SetValue(float64(done) / float64(total))
+				}
+				if name != "" {
+					status.SetText(fmt.Sprintf("Скачивание %d из %d: %s", done+1, total, name))
+				}
+			})
+		})
+
+		fyne.Do(func() {
+			progress.Hide()
+			if errors.Is(err, addons.ErrGameRunning) {
+				dialog.ShowError(fmt.Errorf("закройте Deadlock перед импортом набора"), window)
+				return
+			}
+
+			var lines []string
+			lines = append(lines, fmt.Sprintf("Установлено модов: %d", len(result.Installed)))
+			for _, f := range result.Failed {
+				lines = append(lines, fmt.Sprintf("Ошибка: %s — %v", f.Mod.Name, f.Err))
+			}
+			for _, m := range result.Unresolved {
+				lines = append(lines, "Не найден на GameBanana: "+m.Name)
+			}
+			if err != nil {
+				lines = append(lines, err.Error())
+			}
+			dialog.ShowInformation("Импорт набора", strings.Join(lines, "\n"), window)
+			onImported()
+		})
+	}()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	addons "DeadlockHelper/Addons"
+	extractfile "DeadlockHelper/ExtractFile"
+	vpk "DeadlockHelper/VPK"
+	installlog "DeadlockHelper/installedmods"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// installFolderMod собирает VPK из папки с файлами мода и устанавливает его в addons
+// как локальный мод с отрицательным ID
+func installFolderMod(folder, name, dir string) (installlog.InstalledMod, error) {
+	tmpDir, err := os.MkdirTemp("", "mod_pack_")
+	if err != nil {
+		return installlog.InstalledMod{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	packed := filepath.Join(tmpDir, "pak01_dir.vpk")
+	if err := vpk.PackDir(folder, packed); err != nil {
+		return installlog.InstalledMod{}, fmt.Errorf("не удалось собрать VPK: %w", err)
+	}
+	staged, err := extractfile.Stage(packed, "")
+	if err != nil {
+		return installlog.InstalledMod{}, err
+	}
+	defer staged.Discard()
+
+	heroes, _ := addons.Heroes(staged.Path)
+	installed, err := staged.Install(dir)
+	if err != nil {
+		return installlog.InstalledMod{}, err
+	}
+
+	mod := installlog.InstalledMod{
+		Name:      name,
+		Path:      installed.Path,
+		Installed: time.Now(),
+		Enabled:   true,
+		SHA256:    installed.SHA256,
+		Heroes:    heroes,
+	}
+	saved, err := installlog.SaveLocalMod(mod, dir)
+	if err != nil {
+		// Мод, которого нет в журнале, не выключить и не удалить из программы
+		os.Remove(installed.Path)
+		return installlog.InstalledMod{}, err
+	}
+	return saved, nil
+}
+
+// runPack — команда pack: собирает VPK из папки в файл или сразу устанавливает его
+func runPack(args []string) error {
+	fs, root := newFlagSet("pack")
+	out := fs.String("o", "", "записать VPK в файл вместо установки в addons")
+	name := fs.String("name", "", "название мода (по умолчанию имя папки)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("укажите папку с файлами мода")
+	}
+	folder := fs.Arg(0)
+
+	if *out != "" {
+		if err := vpk.PackDir(folder, *out); err != nil {
+			return err
+		}
+		fmt.Println("VPK записан:", *out)
+		return nil
+	}
+
+	dir, err := resolveRoot(*root)
+	if err != nil {
+		return err
+	}
+	if *name == "" {
+		*name = filepath.Base(filepath.Clean(folder))
+	}
+	mod, err := installFolderMod(folder, *name, dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Мод %s установлен: %s\n", mod.Name, mod.Path)
+	return nil
+}
+
+// showCreateModDialog предлагает выбрать папку с файлами мода, собирает из неё VPK
+// и устанавливает его. onInstalled вызывается после установки.
+func showCreateModDialog(window fyne.Window, dir string, onInstalled func()) {
+	dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		if uri == nil {
+			return
+		}
+		folder := uri.Path()
+
+		nameInput := widget.NewEntry()
+		nameInput.SetText(filepath.Base(folder))
+		items := []*widget.FormItem{
+			widget.NewFormItem("Папка", widget.NewLabel(folder)),
+			widget.NewFormItem("Название", nameInput),
+		}
+		dialog.ShowForm("Создать мод из папки", "Создать", "Отмена", items, func(ok bool) {
+			if !ok || nameInput.Text == "" {
+				return
+			}
+			progress := dialog.NewProgressInfinite("Сборка мода", nameInput.Text, window)
+			progress.Show()
+			go func() {
+				mod, err := installFolderMod(folder, nameInput.Text, dir)
+				fyne.Do(func() {
+					progress.Hide()
+					if err != nil {
+						dialog.ShowError(fmt.Errorf("не удалось создать мод: %w", err), window)
+						return
+					}
+					done := dialog.NewInformation("Готово", fmt.Sprintf("Мод %s установлен", mod.Name), window)
+					done.SetOnClosed(onInstalled)
+					done.Show()
+				})
+			}()
+		}, window)
+	}, window)
+}
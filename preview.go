@@ -0,0 +1,218 @@
+package main
+
+import (
+	config "DeadlockHelper/Config"
+	sound "DeadlockHelper/Sound"
+	texture "DeadlockHelper/Texture"
+	installlog "DeadlockHelper/installedmods"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// previewBase возвращает путь в кеше превью без расширения. Одинаковые VPK делят
+// одно превью.
+func previewBase(mod installlog.InstalledMod) (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("mod_%d", mod.ID)
+	if mod.SHA256 != "" {
+		name = mod.SHA256
+	}
+	return filepath.Join(dir, "previews", name), nil
+}
+
+// previewPath возвращает путь к контактному листу мода в кеше превью
+func previewPath(mod installlog.InstalledMod) (string, error) {
+	base, err := previewBase(mod)
+	return base + ".png", err
+}
+
+// modPreview возвращает контактный лист текстур мода, собирая его при первом обращении
+func modPreview(mod installlog.InstalledMod) (string, error) {
+	path, err := previewPath(mod)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := texture.WriteContactSheet(mod.Path, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// previewThumbnail показывает вместо картинки с GameBanana превью из текстур мода.
+// Пока оно собирается, на месте картинки надпись.
+func previewThumbnail(mod installlog.InstalledMod) fyne.CanvasObject {
+	label := widget.NewLabel("Создание превью...")
+	holder := container.NewStack(label)
+	go func() {
+		path, err := modPreview(mod)
+		fyne.Do(func() {
+			if err != nil {
+				label.SetText("Нет изображения")
+				return
+			}
+			image := canvas.NewImageFromFile(path)
+			image.FillMode = canvas.ImageFillContain
+			image.SetMinSize(fyne.NewSize(150, 150))
+			holder.Objects = []fyne.CanvasObject{image}
+			holder.Refresh()
+		})
+	}()
+	return holder
+}
+
+// showPreviewWindow показывает контактный лист текстур мода в отдельном окне
+func showPreviewWindow(a fyne.App, parent fyne.Window, mod installlog.InstalledMod) {
+	progress := dialog.NewProgressInfinite("Превью", mod.Name, parent)
+	progress.Show()
+	go func() {
+		path, err := modPreview(mod)
+		fyne.Do(func() {
+			progress.Hide()
+			if errors.Is(err, texture.ErrNoTextures) {
+				dialog.ShowInformation("Превью", "В моде нет текстур, которые можно показать", parent)
+				return
+			}
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось собрать превью: %w", err), parent)
+				return
+			}
+			image := canvas.NewImageFromFile(path)
+			image.FillMode = canvas.ImageFillContain
+			window := a.NewWindow("Превью: " + mod.Name)
+			window.Resize(fyne.NewSize(3*texture.CellSize, 3*texture.CellSize))
+			window.SetContent(image)
+			window.Show()
+		})
+	}()
+}
+
+// modSounds выгружает звуки мода в папку превью (один раз на VPK) и возвращает
+// список файлов. Папка появляется под своим именем, только когда выгрузка закончена.
+func modSounds(mod installlog.InstalledMod) (string, []string, error) {
+	base, err := previewBase(mod)
+	if err != nil {
+		return "", nil, err
+	}
+	dir := base + "_sounds"
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		tmp := dir + ".tmp"
+		os.RemoveAll(tmp)
+		if _, err := sound.Export(mod.Path, tmp); err != nil {
+			os.RemoveAll(tmp)
+			return "", nil, err
+		}
+		if err := os.MkdirAll(tmp, 0755); err != nil {
+			return "", nil, err
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var files []string
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return dir, files, err
+}
+
+// openFile открывает файл или папку программой по умолчанию
+func openFile(a fyne.App, path string) error {
+	u, err := url.Parse(storage.NewFileURI(path).String())
+	if err != nil {
+		return err
+	}
+	return a.OpenURL(u)
+}
+
+// showModDetails показывает сведения о моде и звуки, которые он подменяет
+func showModDetails(a fyne.App, mod installlog.InstalledMod) {
+	window := a.NewWindow(mod.Name)
+	window.Resize(fyne.NewSize(600, 500))
+
+	state := "выключен"
+	if mod.Enabled {
+		state = "включён"
+	}
+	heroes := strings.Join(mod.Heroes, ", ")
+	if heroes == "" {
+		heroes = "—"
+	}
+	info := widget.NewForm(
+		widget.NewFormItem("Файл", widget.NewLabel(mod.Path)),
+		widget.NewFormItem("Состояние", widget.NewLabel(state)),
+		widget.NewFormItem("Установлен", widget.NewLabel(mod.Installed.Format("02.01.2006 15:04"))),
+		widget.NewFormItem("Герои", widget.NewLabel(heroes)),
+	)
+	if mod.Problem != "" {
+		problem := widget.NewLabel(mod.Problem)
+		problem.Wrapping = fyne.TextWrapWord
+		info.Append("Проблема", problem)
+	}
+
+	sounds := container.NewVBox(widget.NewLabel("Поиск звуков..."))
+	openDirBtn := widget.NewButton("Открыть папку", nil)
+	openDirBtn.Disable()
+	go func() {
+		dir, files, err := modSounds(mod)
+		fyne.Do(func() {
+			sounds.Objects = nil
+			switch {
+			case err != nil:
+				sounds.Add(widget.NewLabel(fmt.Sprintf("Не удалось выгрузить звуки: %v", err)))
+			case len(files) == 0:
+				sounds.Add(widget.NewLabel("Звуков в моде нет"))
+			default:
+				for _, file := range files {
+					file := file
+					rel, _ := filepath.Rel(dir, file)
+					sounds.Add(widget.NewButton(filepath.ToSlash(rel), func() {
+						if err := openFile(a, file); err != nil {
+							dialog.ShowError(err, window)
+						}
+					}))
+				}
+				openDirBtn.OnTapped = func() {
+					if err := openFile(a, dir); err != nil {
+						dialog.ShowError(err, window)
+					}
+				}
+				openDirBtn.Enable()
+			}
+			sounds.Refresh()
+		})
+	}()
+
+	buttons := container.NewHBox(
+		widget.NewButton("Превью", func() { showPreviewWindow(a, window, mod) }),
+		widget.NewButton("Содержимое", func() { showVPKBrowser(a, window, mod.Path, mod.Name, nil) }),
+		openDirBtn,
+	)
+	top := container.NewVBox(widget.NewLabelWithStyle(mod.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}), info, buttons, widget.NewLabel("Звуки:"))
+	window.SetContent(container.NewBorder(top, nil, nil, nil, container.NewVScroll(sounds)))
+	window.Show()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	addons "DeadlockHelper/Addons"
+	installlog "DeadlockHelper/installedmods"
+	"errors"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newProfileBar создаёт панель профилей для окна установленных модов: выбор профиля,
+// применение, сохранение текущего набора и удаление. onApplied вызывается после того,
+// как моды в addons переставлены.
+func newProfileBar(window fyne.Window, dir string, onApplied func()) fyne.CanvasObject {
+	profiles, err := installlog.LoadProfiles(dir)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("ошибка загрузки профилей: %w", err), window)
+	}
+
+	profileNames := func(profiles installlog.ProfileSet) []string {
+		var names []string
+		for _, p := range profiles.Profiles {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	profileSelect := widget.NewSelect(profileNames(profiles), nil)
+	profileSelect.PlaceHolder = "Профиль"
+	if profiles.Active != "" {
+		profileSelect.SetSelected(profiles.Active)
+	}
+
+	reloadProfiles := func(selected string) {
+		profiles, err := installlog.LoadProfiles(dir)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка загрузки профилей: %w", err), window)
+			return
+		}
+		profileSelect.Options = profileNames(profiles)
+		profileSelect.ClearSelected()
+		if selected != "" {
+			profileSelect.SetSelected(selected)
+		}
+	}
+
+	applyBtn := widget.NewButton("Применить", func() {
+		profiles, err := installlog.LoadProfiles(dir)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("ошибка загрузки профилей: %w", err), window)
+			return
+		}
+		profile, ok := profiles.Find(profileSelect.Selected)
+		if !ok {
+			dialog.ShowError(fmt.Errorf("выберите профиль"), window)
+			return
+		}
+		missing, err := installlog.ApplyProfile(profile, dir)
+		if errors.Is(err, addons.ErrGameRunning) {
+			dialog.ShowError(fmt.Errorf("закройте Deadlock перед сменой профиля"), window)
+			return
+		}
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("не удалось применить профиль: %w", err), window)
+			return
+		}
+		_ = installlog.SetActiveProfile(profile.Name, dir)
+		if len(missing) > 0 {
+			dialog.ShowInformation("Профиль применён",
+				fmt.Sprintf("Не установлено модов из профиля: %d", len(missing)), window)
+		}
+		onApplied()
+	})
+
+	saveBtn := widget.NewButton("Сохранить как…", func() {
+		nameInput := widget.NewEntry()
+		nameInput.SetText(profileSelect.Selected)
+		items := []*widget.FormItem{widget.NewFormItem("Название", nameInput)}
+		dialog.ShowForm("Сохранить профиль", "Сохранить", "Отмена", items, func(ok bool) {
+			if !ok || nameInput.Text == "" {
+				return
+			}
+			profile, err := installlog.SnapshotProfile(nameInput.Text, dir)
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось прочитать установленные моды: %w", err), window)
+				return
+			}
+			if err := installlog.SaveProfile(profile, dir); err != nil {
+				dialog.ShowError(fmt.Errorf("ошибка сохранения профиля: %w", err), window)
+				return
+			}
+			reloadProfiles(profile.Name)
+		}, window)
+	})
+
+	deleteBtn := widget.NewButton("Удалить профиль", func() {
+		name := profileSelect.Selected
+		if name == "" {
+			return
+		}
+		dialog.ShowConfirm("Удалить профиль", fmt.Sprintf("Удалить профиль %s?", name), func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := installlog.DeleteProfile(name, dir); err != nil {
+				dialog.ShowError(fmt.Errorf("ошибка удаления профиля: %w", err), window)
+				return
+			}
+			reloadProfiles("")
+		}, window)
+	})
+
+	return container.NewHBox(profileSelect, applyBtn, saveBtn, deleteBtn)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	gamebanana "DeadlockHelper/Parser"
+	store "DeadlockHelper/Store"
+	installlog "DeadlockHelper/installedmods"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// reconcileMods сверяет журнал с папкой addons в фоне и показывает найденные
+// расхождения. onChanged вызывается при закрытии окна, если журнал изменился.
+func reconcileMods(a fyne.App, parent fyne.Window, dir string, onChanged func()) {
+	status := widget.NewLabel("Подготовка…")
+	bar := widget.NewProgressBar()
+	progress := dialog.NewCustomWithoutButtons("Сверка с addons", container.NewVBox(status, bar), parent)
+	progress.Show()
+
+	go func() {
+		issues, err := installlog.Reconcile(dir, func(done, total int, name string) {
+			fyne.Do(func() {
+				if total > 0 {
+					bar.SetValue(float64(done) / float64(total))
+				}
+				if name != "" {
+					status.SetText(fmt.Sprintf("Проверка %d из %d: %s", done+1, total, name))
+				}
+			})
+		})
+		fyne.Do(func() {
+			progress.Hide()
+			if err != nil {
+				dialog.ShowError(fmt.Errorf("не удалось сверить моды: %w", err), parent)
+				return
+			}
+			if len(issues) == 0 {
+				dialog.ShowInformation("Сверка с addons", "Журнал совпадает с папкой addons", parent)
+				return
+			}
+			showReconcileWindow(a, issues, dir, onChanged)
+		})
+	}()
+}
+
+// showReconcileWindow показывает расхождения с действиями для каждого из них
+func showReconcileWindow(a fyne.App, issues []installlog.Issue, dir string, onChanged func()) {
+	window := a.NewWindow("Сверка с addons")
+	window.Resize(fyne.NewSize(700, 500))
+
+	changed := false
+	window.SetOnClosed(func() {
+		if changed {
+			onChanged()
+		}
+	})
+
+	list := container.NewVBox()
+	for _, issue := range issues {
+		list.Add(newIssueRow(window, issue, dir, func() { changed = true }))
+		list.Add(widget.NewSeparator())
+	}
+	header := widget.NewLabel(fmt.Sprintf("Найдено расхождений: %d", len(issues)))
+	window.SetContent(container.NewBorder(header, nil, nil, nil, container.NewVScroll(list)))
+	window.Show()
+}
+
+// newIssueRow собирает строку с описанием расхождения и кнопками для него.
+// После выполненного действия кнопки выключаются, а в строке пишется итог.
+func newIssueRow(window fyne.Window, issue installlog.Issue, dir string, onChanged func()) fyne.CanvasObject {
+	title := issue.Mod.Name
+	if issue.Kind == installlog.IssueUntracked {
+		title = filepath.Base(issue.Path)
+	}
+	result := widget.NewLabel("")
+	result.Wrapping = fyne.TextWrapWord
+	buttons := container.NewHBox()
+
+	// run выполняет действие в фоне и отмечает строку как разобранную
+	run := func(action func() error, done string) {
+		for _, o := range buttons.Objects {
+			o.(*widget.Button).Disable()
+		}
+		go func() {
+			err := action()
+			fyne.Do(func() {
+				if err != nil {
+					for _, o := range buttons.Objects {
+						o.(*widget.Button).Enable()
+					}
+					if errors.Is(err, store.ErrNotInStore) && !issue.Mod.IsLocal() {
+						askRedownload(window, issue.Mod, dir, func() {
+							for _, o := range buttons.Objects {
+								o.(*widget.Button).Disable()
+							}
+							onChanged()
+							result.SetText("Мод скачивается заново")
+						})
+						return
+					}
+					dialog.ShowError(err, window)
+					return
+				}
+				onChanged()
+				result.SetText(done)
+			})
+		}()
+	}
+	forget := widget.NewButton("Забыть", func() {
+		run(func() error { return installlog.Forget(issue.Mod.ID, dir) }, "Убран из журнала")
+	})
+	reinstall := func(label string) *widget.Button {
+		return widget.NewButton(label, func() {
+			run(func() error { return installlog.Reinstall(issue.Mod.ID, dir) }, "Файл восстановлен")
+		})
+	}
+
+	row := container.NewVBox(
+		widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		widget.NewLabel(fmt.Sprintf("%s: %s", issue.Kind, issue.Path)),
+	)
+	switch issue.Kind {
+	case installlog.IssueUntracked:
+		hint := widget.NewLabel("Поиск на GameBanana…")
+		row.Add(hint)
+		var match gamebanana.Match
+		var matched bool
+		adopt := widget.NewButton("Добавить в журнал", func() {
+			askAdopt(window, issue, match, matched, func(info installlog.InstalledMod) {
+				run(func() error {
+					_, err := installlog.Adopt(issue.Path, info, dir)
+					return err
+				}, "Добавлен в журнал как "+info.Name)
+			})
+		})
+		buttons.Add(adopt)
+		go func() {
+			m, ok, err := gamebanana.MatchFile(issue.Path, issue.MD5)
+			fyne.Do(func() {
+				switch {
+				case err != nil:
+					hint.SetText(fmt.Sprintf("Не удалось найти на GameBanana: %v", err))
+				case !ok:
+					hint.SetText("На GameBanana не найден")
+				default:
+					match, matched = m, true
+					how := "по имени файла"
+					if m.ByMD5 {
+						how = "по MD5"
+					}
+					hint.SetText(fmt.Sprintf("GameBanana: %s (%d), совпадение %s", m.Mod.Name, m.Mod.ID, how))
+				}
+			})
+		}()
+	case installlog.IssueMissing:
+		buttons.Add(reinstall("Переустановить"))
+		buttons.Add(forget)
+	case installlog.IssueChanged:
+		buttons.Add(widget.NewButton("Принять изменения", func() {
+			run(func() error { return installlog.AcceptChange(issue.Mod.ID, dir) }, "Изменения приняты")
+		}))
+		buttons.Add(reinstall("Вернуть исходный"))
+		buttons.Add(forget)
+	}
+	row.Add(buttons)
+	row.Add(result)
+	return row
+}
+
+// askAdopt спрашивает название и ID на GameBanana для VPK, который вносится в журнал.
+// Поля заполняются по найденному совпадению. Пустой ID — локальный мод.
+func askAdopt(window fyne.Window, issue installlog.Issue, match gamebanana.Match, matched bool, onSubmit func(info installlog.InstalledMod)) {
+	nameInput := widget.NewEntry()
+	nameInput.SetText(strings.TrimSuffix(filepath.Base(issue.Path), filepath.Ext(issue.Path)))
+	idInput := widget.NewEntry()
+	if matched {
+		nameInput.SetText(match.Mod.Name)
+		idInput.SetText(strconv.Itoa(match.Mod.ID))
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("Название", nameInput),
+		widget.NewFormItem("ID на GameBanana", idInput),
+	}
+	items[1].HintText = "Оставьте пустым для локального мода"
+
+	dialog.ShowForm("Добавить в журнал", "Добавить", "Отмена", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		info := installlog.InstalledMod{Name: nameInput.Text}
+		if text := strings.TrimSpace(idInput.Text); text != "" {
+			id, err := strconv.Atoi(text)
+			if err != nil || id <= 0 {
+				dialog.ShowError(fmt.Errorf("неверный ID мода: %q", text), window)
+				return
+			}
+			info.ID = id
+		}
+		if matched && info.ID == match.Mod.ID {
+			info.ImageURL = match.Mod.ImageURL()
+			info.FileID = match.File.ID
+			// MD5 с GameBanana относится к скачиваемому файлу, а не к VPK, если это архив
+			if match.ByMD5 {
+				info.MD5 = match.File.MD5
+			}
+		}
+		onSubmit(info)
+	}, window)
+}
+
+// askRedownload предлагает скачать заново мод, копии которого нет в хранилище.
+// Старая запись и изменённый файл удаляются, чтобы мод не оказался в addons дважды.
+func askRedownload(window fyne.Window, mod installlog.InstalledMod, dir string, onStarted func()) {
+	message := fmt.Sprintf("Копии мода %s нет в хранилище. Удалить его из журнала и addons "+
+		"и скачать заново с GameBanana?", mod.Name)
+	dialog.ShowConfirm("Переустановить", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := installlog.DeleteInstalledMod(mod.ID, dir); err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+		onStarted()
+		go func() {
+			// Название и превью берутся с GameBanana, при ошибке — из журнала
+			info, err := gamebanana.FetchMod(mod.ID)
+			if err != nil || info.ID == 0 {
+				info = gamebanana.Mod{ID: mod.ID, Name: mod.Name}
+			}
+			fyne.Do(func() { downloadMod(info, dir, window) })
+		}()
+	}, window)
+}